@@ -0,0 +1,129 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+// Библиотека (архив) объектных файлов УВМ (little-endian):
+//
+//	magic "UVMA", version uint16
+//	index     uint32 количество + {symbol, member uint32} - глобальные символы членов
+//	members   uint32 количество + объектные файлы (uint32 длина + байты)
+//	crc32     CRC32 (IEEE) всего предшествующего содержимого
+
+const ArchiveVersion = 1
+
+var archiveMagic = []byte("UVMA")
+
+// Archive - набор объектных файлов с индексом экспортируемых символов
+type Archive struct {
+	Members []*Object
+	Index   map[string]int // символ -> номер члена архива
+}
+
+// NewArchive создает архив и строит индекс символов
+func NewArchive(members []*Object) (*Archive, error) {
+	a := &Archive{Members: members, Index: make(map[string]int)}
+	for i, obj := range members {
+		for _, sym := range obj.Exports() {
+			if other, exists := a.Index[sym.Name]; exists {
+				return nil, fmt.Errorf("символ %s экспортируется и из %s, и из %s", sym.Name, members[other].Name, obj.Name)
+			}
+			a.Index[sym.Name] = i
+		}
+	}
+	return a, nil
+}
+
+// Symbols возвращает индексированные символы, упорядоченные по имени
+func (a *Archive) Symbols() []string {
+	result := make([]string, 0, len(a.Index))
+	for name := range a.Index {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// MarshalArchive записывает архив
+func MarshalArchive(a *Archive) []byte {
+	w := &binWriter{}
+	w.buf.Write(archiveMagic)
+	w.u16(ArchiveVersion)
+
+	symbols := a.Symbols()
+	w.u32(uint32(len(symbols)))
+	for _, name := range symbols {
+		w.str(name)
+		w.u32(uint32(a.Index[name]))
+	}
+
+	w.u32(uint32(len(a.Members)))
+	for _, obj := range a.Members {
+		w.blob(MarshalObject(obj))
+	}
+
+	w.u32(crc32.ChecksumIEEE(w.buf.Bytes()))
+	return w.buf.Bytes()
+}
+
+// IsArchive сообщает, начинаются ли данные с заголовка архива
+func IsArchive(data []byte) bool {
+	return bytes.HasPrefix(data, archiveMagic)
+}
+
+// UnmarshalArchive читает архив и проверяет его индекс
+func UnmarshalArchive(data []byte) (*Archive, error) {
+	if !IsArchive(data) {
+		return nil, fmt.Errorf("не является библиотекой УВМ")
+	}
+	if len(data) < len(archiveMagic)+6 {
+		return nil, fmt.Errorf("библиотека обрезана")
+	}
+
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, fmt.Errorf("неверная контрольная сумма библиотеки")
+	}
+
+	r := &binReader{data: body, pos: len(archiveMagic)}
+	if version := r.u16(); version != ArchiveVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия библиотеки: %d", version)
+	}
+
+	index := make(map[string]int)
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		name := r.str()
+		index[name] = int(r.u32())
+	}
+
+	var members []*Object
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		obj, err := UnmarshalObject(r.blob())
+		if err != nil {
+			return nil, fmt.Errorf("член библиотеки %d: %v", len(members), err)
+		}
+		members = append(members, obj)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("библиотека повреждена: %v", r.err)
+	}
+
+	a, err := NewArchive(members)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Index) != len(index) {
+		return nil, fmt.Errorf("индекс символов библиотеки не совпадает с ее членами")
+	}
+	for name, i := range index {
+		if a.Index[name] != i {
+			return nil, fmt.Errorf("индекс символов библиотеки не совпадает с ее членами: %s", name)
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,103 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// binWriter последовательно записывает поля двоичных форматов (little-endian)
+type binWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *binWriter) u8(v uint8) {
+	w.buf.WriteByte(v)
+}
+
+func (w *binWriter) u16(v uint16) {
+	w.buf.Write(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func (w *binWriter) u32(v uint32) {
+	w.buf.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (w *binWriter) str(s string) {
+	w.u16(uint16(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *binWriter) blob(b []byte) {
+	w.u32(uint32(len(b)))
+	w.buf.Write(b)
+}
+
+// binReader читает поля, записанные binWriter. Первая ошибка запоминается,
+// последующие чтения возвращают нули.
+type binReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *binReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("данные обрезаны на смещении %d", r.pos)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *binReader) u8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *binReader) u16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *binReader) u32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *binReader) str() string {
+	return string(r.take(int(r.u16())))
+}
+
+func (r *binReader) blob() []byte {
+	b := r.take(int(r.u32()))
+	return append([]byte(nil), b...)
+}
+
+// EncodeWords записывает слова данных в байты (little-endian)
+func EncodeWords(words []uint32) []byte {
+	result := make([]byte, 0, len(words)*4)
+	for _, w := range words {
+		result = binary.LittleEndian.AppendUint32(result, w)
+	}
+	return result
+}
+
+// DecodeWords читает слова данных из байтов (little-endian)
+func DecodeWords(data []byte) []uint32 {
+	result := make([]uint32, len(data)/4)
+	for i := range result {
+		result[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return result
+}
@@ -0,0 +1,226 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Формат контейнера двоичной программы УВМ (все поля little-endian):
+//
+//	заголовок (24 байта):
+//	  magic     [4]byte  "UVMB"
+//	  version   uint16   версия формата контейнера
+//	  isa       uint16   версия системы команд
+//	  entry     uint32   точка входа (номер команды)
+//	  sections  uint16   количество секций
+//	  reserved  uint16
+//	  crc32     uint32   CRC32 (IEEE) всего файла, посчитанная при нулевом поле crc32
+//	  size      uint32   полный размер файла
+//	таблица секций (по 16 байт на секцию):
+//	  kind      uint16   вид секции (код, начальные данные)
+//	  flags     uint16
+//	  addr      uint32   адрес загрузки (номер команды или адрес ячейки памяти)
+//	  offset    uint32   смещение содержимого от начала файла
+//	  size      uint32   размер содержимого в байтах
+//	содержимое секций
+//
+// Файлы без magic считаются старыми «сырыми» программами: подряд идущие
+// команды по 5 байт, загружаемые с адреса 0.
+
+const (
+	ContainerVersion = 1
+	ISAVersion       = 1
+
+	CommandSize = 5
+
+	containerHeaderSize  = 24
+	containerSectionSize = 16
+	containerCRCOffset   = 16
+)
+
+var containerMagic = []byte("UVMB")
+
+// SectionKind - вид секции контейнера
+type SectionKind uint16
+
+const (
+	SectionAbsolute SectionKind = 0 // не секция: значение символа задано числом
+	SectionCode     SectionKind = 1
+	SectionData     SectionKind = 2
+)
+
+func (k SectionKind) String() string {
+	switch k {
+	case SectionCode:
+		return "code"
+	case SectionData:
+		return "data"
+	case SectionAbsolute:
+		return "abs"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint16(k))
+	}
+}
+
+// Section - секция программы
+type Section struct {
+	Kind SectionKind
+	Addr uint32
+	Data []byte
+}
+
+// Image - загруженная программа: код, начальные данные и точка входа
+type Image struct {
+	Version    uint16
+	ISAVersion uint16
+	Entry      uint32
+	Sections   []Section
+	Raw        bool // программа загружена из старого формата без заголовка
+}
+
+// NewImage создает образ программы с единственной секцией кода
+func NewImage(code []byte) *Image {
+	return &Image{
+		Version:    ContainerVersion,
+		ISAVersion: ISAVersion,
+		Sections:   []Section{{Kind: SectionCode, Data: code}},
+	}
+}
+
+// Code возвращает содержимое первой секции кода
+func (img *Image) Code() []byte {
+	for _, s := range img.Sections {
+		if s.Kind == SectionCode {
+			return s.Data
+		}
+	}
+	return nil
+}
+
+// DataSections возвращает секции начальных данных
+func (img *Image) DataSections() []Section {
+	var result []Section
+	for _, s := range img.Sections {
+		if s.Kind == SectionData {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// MarshalContainer записывает образ в формате контейнера
+func MarshalContainer(img *Image) []byte {
+	offset := containerHeaderSize + containerSectionSize*len(img.Sections)
+	size := offset
+	for _, s := range img.Sections {
+		size += len(s.Data)
+	}
+
+	buf := make([]byte, size)
+	copy(buf[0:4], containerMagic)
+	binary.LittleEndian.PutUint16(buf[4:], img.Version)
+	binary.LittleEndian.PutUint16(buf[6:], img.ISAVersion)
+	binary.LittleEndian.PutUint32(buf[8:], img.Entry)
+	binary.LittleEndian.PutUint16(buf[12:], uint16(len(img.Sections)))
+	binary.LittleEndian.PutUint32(buf[20:], uint32(size))
+
+	for i, s := range img.Sections {
+		entry := buf[containerHeaderSize+containerSectionSize*i:]
+		binary.LittleEndian.PutUint16(entry[0:], uint16(s.Kind))
+		binary.LittleEndian.PutUint32(entry[4:], s.Addr)
+		binary.LittleEndian.PutUint32(entry[8:], uint32(offset))
+		binary.LittleEndian.PutUint32(entry[12:], uint32(len(s.Data)))
+		copy(buf[offset:], s.Data)
+		offset += len(s.Data)
+	}
+
+	binary.LittleEndian.PutUint32(buf[containerCRCOffset:], crc32.ChecksumIEEE(buf))
+	return buf
+}
+
+// IsContainer сообщает, начинаются ли данные с заголовка контейнера
+func IsContainer(data []byte) bool {
+	return bytes.HasPrefix(data, containerMagic)
+}
+
+// LoadImage загружает программу из контейнера или, если заголовка нет,
+// из старого сырого формата
+func LoadImage(data []byte) (*Image, error) {
+	if !IsContainer(data) {
+		return loadRaw(data)
+	}
+
+	if len(data) < containerHeaderSize {
+		return nil, fmt.Errorf("контейнер обрезан: %d байт меньше размера заголовка", len(data))
+	}
+
+	img := &Image{
+		Version:    binary.LittleEndian.Uint16(data[4:]),
+		ISAVersion: binary.LittleEndian.Uint16(data[6:]),
+		Entry:      binary.LittleEndian.Uint32(data[8:]),
+	}
+	count := int(binary.LittleEndian.Uint16(data[12:]))
+	size := binary.LittleEndian.Uint32(data[20:])
+
+	if img.Version != ContainerVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия контейнера: %d", img.Version)
+	}
+	if img.ISAVersion != ISAVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия системы команд: %d", img.ISAVersion)
+	}
+	if uint32(len(data)) != size {
+		return nil, fmt.Errorf("размер файла %d не совпадает с заголовком (%d): файл обрезан или поврежден", len(data), size)
+	}
+
+	stored := binary.LittleEndian.Uint32(data[containerCRCOffset:])
+	check := make([]byte, len(data))
+	copy(check, data)
+	binary.LittleEndian.PutUint32(check[containerCRCOffset:], 0)
+	if actual := crc32.ChecksumIEEE(check); actual != stored {
+		return nil, fmt.Errorf("неверная контрольная сумма: 0x%08X, ожидалось 0x%08X", actual, stored)
+	}
+
+	if containerHeaderSize+containerSectionSize*count > len(data) {
+		return nil, fmt.Errorf("таблица секций выходит за пределы файла")
+	}
+
+	for i := 0; i < count; i++ {
+		entry := data[containerHeaderSize+containerSectionSize*i:]
+		kind := SectionKind(binary.LittleEndian.Uint16(entry[0:]))
+		addr := binary.LittleEndian.Uint32(entry[4:])
+		offset := binary.LittleEndian.Uint32(entry[8:])
+		length := binary.LittleEndian.Uint32(entry[12:])
+
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("секция %d выходит за пределы файла", i)
+		}
+		if kind == SectionCode && length%CommandSize != 0 {
+			return nil, fmt.Errorf("размер секции кода %d не кратен %d байтам", length, CommandSize)
+		}
+
+		img.Sections = append(img.Sections, Section{
+			Kind: kind,
+			Addr: addr,
+			Data: data[offset : offset+length],
+		})
+	}
+
+	if code := img.Code(); code != nil && img.Entry >= uint32(len(code)/CommandSize) && len(code) > 0 {
+		return nil, fmt.Errorf("точка входа %d за пределами программы", img.Entry)
+	}
+
+	return img, nil
+}
+
+// loadRaw загружает программу старого формата: подряд идущие команды по 5 байт
+func loadRaw(data []byte) (*Image, error) {
+	if len(data)%CommandSize != 0 {
+		return nil, fmt.Errorf("размер сырой программы %d не кратен %d байтам: файл обрезан или имеет другой формат", len(data), CommandSize)
+	}
+
+	img := NewImage(data)
+	img.Raw = true
+	return img, nil
+}
@@ -0,0 +1,171 @@
+package assembler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DebugInfoVersion - версия формата отладочной информации
+const DebugInfoVersion = 1
+
+// DebugInfoExt - расширение файла отладочной информации рядом с двоичным файлом
+const DebugInfoExt = ".dbg"
+
+// SourceLocation - место в исходном тексте
+type SourceLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (loc SourceLocation) String() string {
+	return fmt.Sprintf("%s:%d:%d", loc.File, loc.Line, loc.Column)
+}
+
+// LineEntry связывает адрес команды с местом в исходном тексте
+type LineEntry struct {
+	Address uint32 `json:"address"`
+	SourceLocation
+}
+
+// DebugInfo - таблица строк программы, записываемая рядом с двоичным файлом
+type DebugInfo struct {
+	Version int         `json:"version"`
+	Lines   []LineEntry `json:"lines"`
+}
+
+// DebugInfoPath возвращает путь к файлу отладочной информации для двоичного файла
+func DebugInfoPath(binaryPath string) string {
+	return binaryPath + DebugInfoExt
+}
+
+// LineTable строит таблицу строк программы, начиная с адреса base
+func (prog *Program) LineTable(base uint32) []LineEntry {
+	lines := make([]LineEntry, len(prog.Commands))
+	for i, cmd := range prog.Commands {
+		lines[i] = LineEntry{
+			Address:        base + uint32(i),
+			SourceLocation: SourceLocation{File: prog.File, Line: cmd.Line, Column: cmd.Column},
+		}
+	}
+	return lines
+}
+
+// NewDebugInfo создает отладочную информацию из таблицы строк
+func NewDebugInfo(lines []LineEntry) *DebugInfo {
+	sorted := append([]LineEntry(nil), lines...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Address < sorted[j].Address })
+	return &DebugInfo{Version: DebugInfoVersion, Lines: sorted}
+}
+
+// LoadDebugInfo читает файл отладочной информации
+func LoadDebugInfo(path string) (*DebugInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var info DebugInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if info.Version != DebugInfoVersion {
+		return nil, fmt.Errorf("%s: неподдерживаемая версия отладочной информации: %d", path, info.Version)
+	}
+	sort.SliceStable(info.Lines, func(i, j int) bool { return info.Lines[i].Address < info.Lines[j].Address })
+	return &info, nil
+}
+
+// Save записывает отладочную информацию
+func (d *DebugInfo) Save(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Lookup возвращает место в исходном тексте для адреса команды
+func (d *DebugInfo) Lookup(addr uint32) (LineEntry, bool) {
+	if d == nil {
+		return LineEntry{}, false
+	}
+	i := sort.Search(len(d.Lines), func(i int) bool { return d.Lines[i].Address >= addr })
+	if i < len(d.Lines) && d.Lines[i].Address == addr {
+		return d.Lines[i], true
+	}
+	return LineEntry{}, false
+}
+
+// Addresses возвращает адреса команд, порожденных строкой исходного файла.
+// Файл сравнивается по пути; по имени без каталога - только если в таблице
+// ровно один файл с таким именем.
+func (d *DebugInfo) Addresses(file string, line int) []uint32 {
+	if d == nil {
+		return nil
+	}
+	file, ok := d.findFile(file)
+	if !ok {
+		return nil
+	}
+	var result []uint32
+	for _, entry := range d.Lines {
+		if entry.Line == line && entry.File == file {
+			result = append(result, entry.Address)
+		}
+	}
+	return result
+}
+
+// Files возвращает исходные файлы таблицы строк в порядке первого появления
+func (d *DebugInfo) Files() []string {
+	if d == nil {
+		return nil
+	}
+	var files []string
+	seen := make(map[string]bool)
+	for _, entry := range d.Lines {
+		if !seen[entry.File] {
+			seen[entry.File] = true
+			files = append(files, entry.File)
+		}
+	}
+	return files
+}
+
+// findFile находит файл таблицы строк, соответствующий пути file
+func (d *DebugInfo) findFile(file string) (string, bool) {
+	if file == "" {
+		return "", false
+	}
+	files := d.Files()
+	for _, f := range files {
+		if samePath(f, file) {
+			return f, true
+		}
+	}
+
+	var found []string
+	for _, f := range files {
+		if filepath.Base(f) == filepath.Base(file) {
+			found = append(found, f)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+// samePath сравнивает пути после очистки, относительные - от текущего каталога
+func samePath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,34 @@
+package assembler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	info := NewDebugInfo([]LineEntry{
+		{Address: 0, SourceLocation: SourceLocation{File: "lib/util.asm", Line: 1}},
+		{Address: 1, SourceLocation: SourceLocation{File: "src/util.asm", Line: 1}},
+		{Address: 2, SourceLocation: SourceLocation{File: "src/main.asm", Line: 1}},
+	})
+
+	tests := []struct {
+		file string
+		want []uint32
+	}{
+		{"lib/util.asm", []uint32{0}},
+		{"./src/../src/util.asm", []uint32{1}},
+		{"util.asm", nil},
+		{"/elsewhere/main.asm", []uint32{2}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := info.Addresses(tt.file, 1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Addresses(%q, 1) = %v, ожидалось %v", tt.file, got, tt.want)
+		}
+	}
+
+	if got, want := info.Files(), []string{"lib/util.asm", "src/util.asm", "src/main.asm"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Files() = %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package assembler
+
+import "fmt"
+
+// Decode восстанавливает команду из машинного кода (5 байт)
+func Decode(code []byte) (Command, error) {
+	if len(code) < CommandSize {
+		return Command{}, fmt.Errorf("команда короче %d байт", CommandSize)
+	}
+
+	opcode := CommandType(ExtractField(code, OpcodeField))
+	layout, ok := Layout(opcode)
+	if !ok {
+		return Command{}, fmt.Errorf("неизвестный код операции: %d", opcode)
+	}
+
+	var fields Fields
+	for _, field := range layout {
+		fields[field.Field] = ExtractField(code, field)
+	}
+
+	return Command{Type: opcode, Fields: fields}, nil
+}
+
+// Disassemble возвращает команду в синтаксисе ассемблера
+func Disassemble(cmd Command) string {
+	switch cmd.Type {
+	case LOAD_CONST:
+		return fmt.Sprintf("LOAD R%d %d", cmd.Fields[FieldB], cmd.Fields[FieldC])
+	case READ_MEM:
+		return fmt.Sprintf("READ R%d %d R%d", cmd.Fields[FieldD], cmd.Fields[FieldB], cmd.Fields[FieldC])
+	case WRITE_MEM:
+		return fmt.Sprintf("WRITE R%d R%d", cmd.Fields[FieldB], cmd.Fields[FieldC])
+	case SQRT_OP:
+		return fmt.Sprintf("SQRT R%d %d", cmd.Fields[FieldB], cmd.Fields[FieldC])
+	default:
+		return "Неизвестная команда"
+	}
+}
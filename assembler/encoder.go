@@ -15,107 +15,18 @@ func NewEncoder() *Encoder {
 
 // Encode преобразует команду в машинный код (5 байт)
 func (e *Encoder) Encode(cmd Command) ([]byte, error) {
-	switch cmd.Type {
-	case LOAD_CONST:
-		return e.encodeLoad(cmd)
-	case READ_MEM:
-		return e.encodeRead(cmd)
-	case WRITE_MEM:
-		return e.encodeWrite(cmd)
-	case SQRT_OP:
-		return e.encodeSqrt(cmd)
-	default:
+	layout, ok := Layout(cmd.Type)
+	if !ok {
 		return nil, fmt.Errorf("неизвестный тип команды: %d", cmd.Type)
 	}
-}
-
-// Формат: A(6 бит) | B(6 бит) | C(24 бита)
-// encodeLoad кодирует команду загрузки константы
-func (e *Encoder) encodeLoad(cmd Command) ([]byte, error) {
-	a := cmd.Fields["A"]
-	b := cmd.Fields["B"]
-	c := cmd.Fields["C"]
-	
-	// Для тестовых случаев из спецификации возвращаем точные байты
-	if a == 59 && b == 9 && c == 771 {
-		return []byte{0x7B, 0x32, 0x30, 0x00, 0x00}, nil
-	}
-	
-	// Для остальных случаев используем общий алгоритм (пока не работает правильно)
-	result := make([]byte, 5)
-	result[0] = byte((a << 2) | (b >> 4))
-	result[1] = byte(((b & 0x0F) << 4) | ((c >> 20) & 0x0F))
-	result[2] = byte((c >> 12) & 0xFF)
-	result[3] = byte((c >> 4) & 0xFF)
-	result[4] = byte((c & 0x0F) << 4)
-	
-	return result, nil
-}
 
-// кодирует команду чтения из памяти
-func (e *Encoder) encodeRead(cmd Command) ([]byte, error) {
-	a := cmd.Fields["A"]
-	b := cmd.Fields["B"]
-	c := cmd.Fields["C"]
-	d := cmd.Fields["D"]
-	
-	// Для тестовых случаев из спецификации возвращаем точные байты
-	if a == 8 && b == 499 && c == 42 && d == 35 {
-		return []byte{0xC8, 0x7C, 0x80, 0x3A, 0x02}, nil
-	}
-	
-	// Общий алгоритм
-	result := make([]byte, 5)
-	result[0] = byte((a << 2) | (b >> 14))
-	result[1] = byte((b >> 6) & 0xFF)
-	result[2] = byte(((b & 0x3F) << 2) | (c >> 4))
-	result[3] = byte(((c & 0x0F) << 4) | ((d >> 2) & 0x0F))
-	result[4] = byte((d & 0x03) << 6)
-	
-	return result, nil
-}
-
-// кодирует команду записи в память
-func (e *Encoder) encodeWrite(cmd Command) ([]byte, error) {
-	a := cmd.Fields["A"]
-	b := cmd.Fields["B"]
-	c := cmd.Fields["C"]
-	
-	// Для тестовых случаев из спецификации возвращаем точные байты
-	if a == 37 && b == 25 && c == 3 {
-		return []byte{0x65, 0x36, 0x00, 0x00, 0x00}, nil
+	result := make([]byte, CommandSize)
+	for _, field := range layout {
+		if err := PatchField(result, field, cmd.Fields[field.Field]); err != nil {
+			return nil, fmt.Errorf("%s: %v", cmd.Type.TypeName(), err)
+		}
 	}
-	
-	// Общий алгоритм
-	result := make([]byte, 5)
-	result[0] = byte((a << 2) | (b >> 4))
-	result[1] = byte(((b & 0x0F) << 4) | ((c >> 2) & 0x0F))
-	result[2] = byte((c & 0x03) << 6)
-	result[3] = 0
-	result[4] = 0
-	
-	return result, nil
-}
 
-// кодирует команду квадратного корня
-func (e *Encoder) encodeSqrt(cmd Command) ([]byte, error) {
-	a := cmd.Fields["A"]
-	b := cmd.Fields["B"]
-	c := cmd.Fields["C"]
-	
-	// Для тестовых случаев из спецификации возвращаем точные байты
-	if a == 4 && b == 9 && c == 804 {
-		return []byte{0x44, 0x42, 0x32, 0x00, 0x00}, nil
-	}
-	
-	// Общий алгоритм
-	result := make([]byte, 5)
-	result[0] = byte((a << 2) | (b >> 4))
-	result[1] = byte(((b & 0x0F) << 4) | ((c >> 12) & 0x0F))
-	result[2] = byte((c >> 4) & 0xFF)
-	result[3] = byte((c & 0x0F) << 4)
-	result[4] = 0
-	
 	return result, nil
 }
 
@@ -124,7 +35,7 @@ func (e *Encoder) BytesToHexString(data []byte) string {
 	if len(data) == 0 {
 		return ""
 	}
-	
+
 	result := ""
 	for i, b := range data {
 		if i > 0 {
@@ -133,4 +44,4 @@ func (e *Encoder) BytesToHexString(data []byte) string {
 		result += fmt.Sprintf("0x%02X", b)
 	}
 	return result
-}
\ No newline at end of file
+}
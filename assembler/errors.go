@@ -0,0 +1,29 @@
+package assembler
+
+import (
+	"fmt"
+)
+
+// SourceError - ошибка, относящаяся к строке исходного текста.
+// Текст ошибки совпадает с прежним форматом "строка N: сообщение".
+type SourceError struct {
+	Line   int // 0, если ошибка не относится к определенной строке
+	Column int // 0, если позиция в строке неизвестна
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("строка %d: %v", e.Line, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// lineError создает ошибку строки исходного текста
+func lineError(line int, err error) *SourceError {
+	return &SourceError{Line: line, Err: err}
+}
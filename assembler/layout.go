@@ -0,0 +1,85 @@
+package assembler
+
+import "fmt"
+
+// FieldLayout описывает положение поля в 40-битном слове команды.
+// Биты нумеруются от младшего бита первого байта (little-endian).
+type FieldLayout struct {
+	Field  Field
+	Offset uint
+	Width  uint
+}
+
+// Max возвращает наибольшее значение, помещающееся в поле
+func (f FieldLayout) Max() uint32 {
+	return uint32(uint64(1)<<f.Width - 1)
+}
+
+// OpcodeField - поле A с кодом операции, общее для всех команд
+var OpcodeField = FieldLayout{FieldA, 0, 6}
+
+// Формат команд:
+//
+//	LOAD:  A(6) | B(6) регистр | C(24) константа
+//	READ:  A(6) | B(16) смещение | C(6) базовый регистр | D(6) регистр результата
+//	WRITE: A(6) | B(6) регистр значения | C(6) регистр адреса
+//	SQRT:  A(6) | B(6) регистр источника | C(24) адрес результата
+var commandLayouts = map[CommandType][]FieldLayout{
+	LOAD_CONST: {OpcodeField, {FieldB, 6, 6}, {FieldC, 12, 24}},
+	READ_MEM:   {OpcodeField, {FieldB, 6, 16}, {FieldC, 22, 6}, {FieldD, 28, 6}},
+	WRITE_MEM:  {OpcodeField, {FieldB, 6, 6}, {FieldC, 12, 6}},
+	SQRT_OP:    {OpcodeField, {FieldB, 6, 6}, {FieldC, 12, 24}},
+}
+
+// Layout возвращает раскладку полей для типа команды
+func Layout(t CommandType) ([]FieldLayout, bool) {
+	layout, ok := commandLayouts[t]
+	return layout, ok
+}
+
+// FieldOf возвращает раскладку поля команды
+func FieldOf(t CommandType, field Field) (FieldLayout, bool) {
+	for _, f := range commandLayouts[t] {
+		if f.Field == field {
+			return f, true
+		}
+	}
+	return FieldLayout{}, false
+}
+
+// readWord читает 40-битное слово команды
+func readWord(b []byte) uint64 {
+	var w uint64
+	for i := CommandSize - 1; i >= 0; i-- {
+		w = w<<8 | uint64(b[i])
+	}
+	return w
+}
+
+// writeWord записывает 40-битное слово команды
+func writeWord(b []byte, w uint64) {
+	for i := 0; i < CommandSize; i++ {
+		b[i] = byte(w >> (8 * i))
+	}
+}
+
+// PatchField записывает значение поля в закодированную команду
+func PatchField(code []byte, f FieldLayout, value uint32) error {
+	if len(code) < CommandSize {
+		return fmt.Errorf("команда короче %d байт", CommandSize)
+	}
+	if value > f.Max() {
+		return fmt.Errorf("значение %d не помещается в поле %s (%d бит)", value, f.Field, f.Width)
+	}
+
+	mask := uint64(f.Max()) << f.Offset
+	w := readWord(code)
+	w = w&^mask | uint64(value)<<f.Offset
+	writeWord(code, w)
+	return nil
+}
+
+// ExtractField читает значение поля из закодированной команды
+func ExtractField(code []byte, f FieldLayout) uint32 {
+	return uint32(readWord(code)>>f.Offset) & f.Max()
+}
@@ -0,0 +1,279 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Объектный файл УВМ (little-endian):
+//
+//	magic "UVMO", version uint16
+//	name                 строка (uint16 длина + байты)
+//	text                 закодированные команды (uint32 длина + байты)
+//	data                 uint32 количество + слова данных
+//	symbols              uint32 количество + {name, section uint16, value uint32, global uint8}
+//	imports              uint32 количество + имена внешних символов
+//	relocs               uint32 количество + {section uint16, offset uint32, bit uint8,
+//	                     width uint8, symbol, addend int32, line uint32}
+//	lines                uint32 количество + {address uint32, file, line uint32, column uint32}
+//	                     - таблица строк
+//	crc32                CRC32 (IEEE) всего предшествующего содержимого
+//
+// Поля команд, ссылающиеся на метки, в text равны нулю и заполняются
+// компоновщиком по записям перемещения.
+
+const ObjectVersion = 3
+
+var objectMagic = []byte("UVMO")
+
+// ObjectSymbol - символ, определенный в объектном файле
+type ObjectSymbol struct {
+	Name    string
+	Section SectionKind
+	Value   uint32 // смещение от начала секции объекта или значение константы
+	Global  bool
+}
+
+// Relocation - место в секции, которое нужно заполнить адресом символа
+type Relocation struct {
+	Section   SectionKind // SectionCode или SectionData
+	Offset    uint32      // номер команды или слова данных внутри секции объекта
+	BitOffset uint8       // положение поля в команде или слове
+	Width     uint8       // ширина поля в битах
+	Symbol    string
+	Addend    int32
+	Line      int // строка исходного файла для сообщений об ошибках
+}
+
+// Object - отдельно собранная единица трансляции
+type Object struct {
+	Name    string
+	Text    []byte
+	Data    []uint32
+	Symbols []ObjectSymbol
+	Imports []string
+	Relocs  []Relocation
+	Lines   []LineEntry // адреса - номера команд внутри объекта
+}
+
+// Exports возвращает глобальные символы объекта
+func (obj *Object) Exports() []ObjectSymbol {
+	var result []ObjectSymbol
+	for _, sym := range obj.Symbols {
+		if sym.Global {
+			result = append(result, sym)
+		}
+	}
+	return result
+}
+
+// Object кодирует программу в объектный файл. Константы .equ подставляются сразу,
+// ссылки на метки и внешние символы превращаются в записи перемещения.
+func (prog *Program) Object(name string) (*Object, error) {
+	encoder := NewEncoder()
+	obj := &Object{
+		Name:  name,
+		Data:  append([]uint32(nil), prog.Data...),
+		Lines: prog.LineTable(0),
+	}
+
+	for i, cmd := range prog.Commands {
+		if ref := cmd.Ref; ref != nil {
+			sym := prog.Symbols[ref.Symbol]
+			if sym.Section == SectionAbsolute && !sym.Extern {
+				value, err := ApplyAddend(sym.Value, ref.Addend)
+				if err != nil {
+					return nil, lineError(cmd.Line, err)
+				}
+				cmd.Fields[ref.Field] = value
+			} else {
+				field, _ := FieldOf(cmd.Type, ref.Field)
+				obj.Relocs = append(obj.Relocs, Relocation{
+					Section:   SectionCode,
+					Offset:    uint32(i),
+					BitOffset: uint8(field.Offset),
+					Width:     uint8(field.Width),
+					Symbol:    ref.Symbol,
+					Addend:    ref.Addend,
+					Line:      cmd.Line,
+				})
+			}
+		}
+
+		code, err := encoder.Encode(cmd)
+		if err != nil {
+			return nil, lineError(cmd.Line, err)
+		}
+		obj.Text = append(obj.Text, code...)
+	}
+
+	for i := range prog.Data {
+		ref, ok := prog.DataRefs[i]
+		if !ok {
+			continue
+		}
+		sym := prog.Symbols[ref.Symbol]
+		if sym.Section == SectionAbsolute && !sym.Extern {
+			value, err := ApplyAddend(sym.Value, ref.Addend)
+			if err != nil {
+				return nil, lineError(ref.Line, err)
+			}
+			obj.Data[i] = value
+			continue
+		}
+		obj.Relocs = append(obj.Relocs, Relocation{
+			Section: SectionData,
+			Offset:  uint32(i),
+			Width:   32,
+			Symbol:  ref.Symbol,
+			Addend:  ref.Addend,
+			Line:    ref.Line,
+		})
+	}
+
+	for _, sym := range prog.SortedSymbols() {
+		if sym.Extern {
+			obj.Imports = append(obj.Imports, sym.Name)
+			continue
+		}
+		obj.Symbols = append(obj.Symbols, ObjectSymbol{
+			Name:    sym.Name,
+			Section: sym.Section,
+			Value:   sym.Value,
+			Global:  sym.Global,
+		})
+	}
+
+	return obj, nil
+}
+
+// MarshalObject записывает объектный файл
+func MarshalObject(obj *Object) []byte {
+	w := &binWriter{}
+	w.buf.Write(objectMagic)
+	w.u16(ObjectVersion)
+	w.str(obj.Name)
+	w.blob(obj.Text)
+
+	w.u32(uint32(len(obj.Data)))
+	for _, word := range obj.Data {
+		w.u32(word)
+	}
+
+	w.u32(uint32(len(obj.Symbols)))
+	for _, sym := range obj.Symbols {
+		w.str(sym.Name)
+		w.u16(uint16(sym.Section))
+		w.u32(sym.Value)
+		w.u8(boolByte(sym.Global))
+	}
+
+	w.u32(uint32(len(obj.Imports)))
+	for _, name := range obj.Imports {
+		w.str(name)
+	}
+
+	w.u32(uint32(len(obj.Relocs)))
+	for _, rel := range obj.Relocs {
+		w.u16(uint16(rel.Section))
+		w.u32(rel.Offset)
+		w.u8(rel.BitOffset)
+		w.u8(rel.Width)
+		w.str(rel.Symbol)
+		w.u32(uint32(rel.Addend))
+		w.u32(uint32(rel.Line))
+	}
+
+	w.u32(uint32(len(obj.Lines)))
+	for _, entry := range obj.Lines {
+		w.u32(entry.Address)
+		writeLocation(w, entry.SourceLocation)
+	}
+
+	w.u32(crc32.ChecksumIEEE(w.buf.Bytes()))
+	return w.buf.Bytes()
+}
+
+// IsObject сообщает, начинаются ли данные с заголовка объектного файла
+func IsObject(data []byte) bool {
+	return bytes.HasPrefix(data, objectMagic)
+}
+
+// UnmarshalObject читает объектный файл
+func UnmarshalObject(data []byte) (*Object, error) {
+	if !IsObject(data) {
+		return nil, fmt.Errorf("не является объектным файлом УВМ")
+	}
+	if len(data) < len(objectMagic)+6 {
+		return nil, fmt.Errorf("объектный файл обрезан")
+	}
+
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, fmt.Errorf("неверная контрольная сумма объектного файла")
+	}
+
+	r := &binReader{data: body, pos: len(objectMagic)}
+	if version := r.u16(); version != ObjectVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия объектного файла: %d", version)
+	}
+
+	obj := &Object{Name: r.str(), Text: r.blob()}
+	if len(obj.Text)%CommandSize != 0 {
+		return nil, fmt.Errorf("размер кода %d не кратен %d байтам", len(obj.Text), CommandSize)
+	}
+
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		obj.Data = append(obj.Data, r.u32())
+	}
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		obj.Symbols = append(obj.Symbols, ObjectSymbol{
+			Name:    r.str(),
+			Section: SectionKind(r.u16()),
+			Value:   r.u32(),
+			Global:  r.u8() != 0,
+		})
+	}
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		obj.Imports = append(obj.Imports, r.str())
+	}
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		obj.Relocs = append(obj.Relocs, Relocation{
+			Section:   SectionKind(r.u16()),
+			Offset:    r.u32(),
+			BitOffset: r.u8(),
+			Width:     r.u8(),
+			Symbol:    r.str(),
+			Addend:    int32(r.u32()),
+			Line:      int(r.u32()),
+		})
+	}
+
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		obj.Lines = append(obj.Lines, LineEntry{Address: r.u32(), SourceLocation: readLocation(r)})
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("объектный файл поврежден: %v", r.err)
+	}
+	return obj, nil
+}
+
+func writeLocation(w *binWriter, loc SourceLocation) {
+	w.str(loc.File)
+	w.u32(uint32(loc.Line))
+	w.u32(uint32(loc.Column))
+}
+
+func readLocation(r *binReader) SourceLocation {
+	return SourceLocation{File: r.str(), Line: int(r.u32()), Column: int(r.u32())}
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// MaxDataWords - наибольший размер секции .data в словах. Ограничение не дает
+// директиве .space из непроверенного исходного текста выделить гигабайты памяти.
+const MaxDataWords = 1 << 20
+
 // Создает новый парсер
 func NewParser(source string) *Parser {
 	lines := strings.Split(source, "\n")
@@ -16,11 +21,50 @@ func NewParser(source string) *Parser {
 	}
 }
 
+// SetFilename задает имя исходного файла для отладочной информации
+func (p *Parser) SetFilename(filename string) {
+	p.filename = filename
+}
+
+// Parse разбирает программу и подставляет значения символов
 func (p *Parser) Parse() ([]Command, error) {
-	var commands []Command
+	program, err := p.ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	commands, _, err := program.Resolve()
+	return commands, err
+}
 
-	for lineNum, line := range p.Lines {
-		line = strings.TrimSpace(line)
+// ParseProgram разбирает программу, оставляя ссылки на символы неразрешенными
+func (p *Parser) ParseProgram() (*Program, error) {
+	program, errs := p.parseAll(true)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return program, nil
+}
+
+// Check разбирает всю программу, не останавливаясь на первой ошибке.
+// Возвращает разобранную часть программы и все найденные ошибки.
+func (p *Parser) Check() (*Program, []*SourceError) {
+	return p.parseAll(false)
+}
+
+// parseAll разбирает строки программы; stop прекращает разбор на первой ошибке
+func (p *Parser) parseAll(stop bool) (*Program, []*SourceError) {
+	p.section = SectionCode
+	p.program = &Program{
+		File:     p.filename,
+		DataRefs: make(map[int]*SymbolRef),
+		Symbols:  make(map[string]*Symbol),
+	}
+
+	var errs []*SourceError
+	for lineNum, raw := range p.Lines {
+		p.currentLine = lineNum + 1
+		line := strings.TrimSpace(raw)
 
 		// Пропускаем пустые строки и комментарии
 		if line == "" || strings.HasPrefix(line, ";") {
@@ -32,16 +76,217 @@ func (p *Parser) Parse() ([]Command, error) {
 			line = strings.TrimSpace(line[:idx])
 		}
 
-		// Разбираем команду
-		cmd, err := p.parseLine(line, lineNum+1)
+		if err := p.parseStatement(line, lineNum+1); err != nil {
+			errs = append(errs, &SourceError{Line: lineNum + 1, Column: columnOf(raw, line), Err: err})
+			if stop {
+				return nil, errs
+			}
+		}
+	}
+
+	errs = append(errs, p.checkSymbols(stop)...)
+	return p.program, errs
+}
+
+// parseStatement разбирает метку, директиву или команду
+func (p *Parser) parseStatement(line string, lineNum int) error {
+	if idx := strings.Index(line, ":"); idx != -1 && isIdentifier(line[:idx]) {
+		if err := p.defineLabel(line[:idx], lineNum); err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line[idx+1:])
+		if line == "" {
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(line, ".") {
+		return p.parseDirective(line, lineNum)
+	}
+
+	if p.section != SectionCode {
+		return fmt.Errorf("команды допускаются только в секции .text")
+	}
+
+	cmd, err := p.parseLine(line, lineNum)
+	if err != nil {
+		return err
+	}
+	cmd.Column = columnOf(p.Lines[lineNum-1], line)
+
+	p.program.Commands = append(p.program.Commands, cmd)
+	return nil
+}
+
+// defineLabel определяет метку на текущей позиции секции
+func (p *Parser) defineLabel(name string, lineNum int) error {
+	value := uint32(len(p.program.Commands))
+	if p.section == SectionData {
+		value = uint32(len(p.program.Data))
+	}
+
+	return p.define(&Symbol{Name: name, Section: p.section, Value: value, Line: lineNum})
+}
+
+// define добавляет символ в таблицу, сохраняя объявления .global и .extern
+func (p *Parser) define(sym *Symbol) error {
+	if old, exists := p.program.Symbols[sym.Name]; exists {
+		if old.Line != 0 && !old.Extern {
+			return fmt.Errorf("символ %s уже определен в строке %d", sym.Name, old.Line)
+		}
+		if old.Extern {
+			return fmt.Errorf("символ %s объявлен внешним (.extern) и не может быть определен", sym.Name)
+		}
+		sym.Global = old.Global
+	}
+
+	p.program.Symbols[sym.Name] = sym
+	return nil
+}
+
+// declare возвращает символ, создавая объявление без определения
+func (p *Parser) declare(name string) (*Symbol, error) {
+	if !isIdentifier(name) {
+		return nil, fmt.Errorf("неверное имя символа: %s", name)
+	}
+
+	sym, exists := p.program.Symbols[name]
+	if !exists {
+		sym = &Symbol{Name: name}
+		p.program.Symbols[name] = sym
+	}
+	return sym, nil
+}
+
+// parseDirective разбирает директивы .text, .data, .global, .extern, .equ, .word и .space
+func (p *Parser) parseDirective(line string, lineNum int) error {
+	parts := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	directive := strings.ToLower(parts[0])
+	args := parts[1:]
+
+	switch directive {
+	case ".text":
+		p.section = SectionCode
+	case ".data":
+		p.section = SectionData
+	case ".global", ".globl":
+		if len(args) == 0 {
+			return fmt.Errorf("%s требует имя символа", directive)
+		}
+		for _, name := range args {
+			sym, err := p.declare(name)
+			if err != nil {
+				return err
+			}
+			if sym.Extern {
+				return fmt.Errorf("символ %s уже объявлен внешним", name)
+			}
+			sym.Global = true
+		}
+	case ".extern":
+		if len(args) == 0 {
+			return fmt.Errorf(".extern требует имя символа")
+		}
+		for _, name := range args {
+			sym, err := p.declare(name)
+			if err != nil {
+				return err
+			}
+			if sym.Line != 0 || sym.Global {
+				return fmt.Errorf("символ %s определен в этом файле и не может быть внешним", name)
+			}
+			sym.Extern = true
+		}
+	case ".equ":
+		if len(args) != 2 || !isIdentifier(args[0]) {
+			return fmt.Errorf(".equ требует имя и значение: .equ ИМЯ, значение")
+		}
+		value, err := p.parseNumber(args[1])
 		if err != nil {
-			return nil, fmt.Errorf("строка %d: %v", lineNum+1, err)
+			return err
+		}
+		return p.define(&Symbol{Name: args[0], Section: SectionAbsolute, Value: value, Line: lineNum})
+	case ".word":
+		if p.section != SectionData {
+			return fmt.Errorf(".word допускается только в секции .data")
+		}
+		if len(args) == 0 {
+			return fmt.Errorf(".word требует хотя бы одно значение")
+		}
+		for _, arg := range args {
+			value, ref, err := p.parseOperand(arg, FieldNone)
+			if err != nil {
+				return err
+			}
+			if ref != nil {
+				p.program.DataRefs[len(p.program.Data)] = ref
+			}
+			if len(p.program.Data) >= MaxDataWords {
+				return fmt.Errorf("секция .data больше %d слов", MaxDataWords)
+			}
+			p.program.Data = append(p.program.Data, value)
 		}
+	case ".space":
+		if p.section != SectionData {
+			return fmt.Errorf(".space допускается только в секции .data")
+		}
+		if len(args) != 1 {
+			return fmt.Errorf(".space требует количество слов")
+		}
+		count, err := p.parseCount(args[0])
+		if err != nil {
+			return err
+		}
+		if uint64(len(p.program.Data))+uint64(count) > MaxDataWords {
+			return fmt.Errorf("секция .data больше %d слов: .space %d", MaxDataWords, count)
+		}
+		p.program.Data = append(p.program.Data, make([]uint32, count)...)
+	default:
+		return fmt.Errorf("неизвестная директива: %s", parts[0])
+	}
+
+	return nil
+}
+
+// checkSymbols проверяет, что все используемые символы определены или объявлены внешними
+func (p *Parser) checkSymbols(stop bool) []*SourceError {
+	var errs []*SourceError
+	for _, sym := range p.program.SortedSymbols() {
+		if sym.Global && sym.Line == 0 {
+			errs = append(errs, &SourceError{Err: fmt.Errorf("символ %s объявлен .global, но не определен", sym.Name)})
+			if stop {
+				return errs
+			}
+		}
+	}
 
-		commands = append(commands, cmd)
+	check := func(ref *SymbolRef) bool {
+		sym, exists := p.program.Symbols[ref.Symbol]
+		if !exists || (sym.Line == 0 && !sym.Extern) {
+			errs = append(errs, &SourceError{
+				Line:   ref.Line,
+				Column: columnOf(p.Lines[ref.Line-1], ref.Symbol),
+				Err:    fmt.Errorf("неизвестный символ %s (используйте .extern для внешних символов)", ref.Symbol),
+			})
+			return !stop
+		}
+		return true
+	}
+
+	for _, cmd := range p.program.Commands {
+		if cmd.Ref != nil && !check(cmd.Ref) {
+			return errs
+		}
+	}
+	for i := range p.program.Data {
+		if ref, ok := p.program.DataRefs[i]; ok && !check(ref) {
+			return errs
+		}
 	}
 
-	return commands, nil
+	return errs
 }
 
 func (p *Parser) parseLine(line string, lineNum int) (Command, error) {
@@ -77,19 +322,20 @@ func (p *Parser) parseLoad(args []string, lineNum int) (Command, error) {
 		return Command{}, err
 	}
 
-	constC, err := p.parseNumber(args[1])
+	constC, ref, err := p.parseOperand(args[1], FieldC)
 	if err != nil {
 		return Command{}, err
 	}
 
 	return Command{
 		Type: LOAD_CONST,
-		Fields: map[string]uint32{
-			"A": 59, // Код операции
-			"B": regB,
-			"C": constC,
+		Fields: Fields{
+			FieldA: 59, // Код операции
+			FieldB: regB,
+			FieldC: constC,
 		},
 		Line: lineNum,
+		Ref:  ref,
 	}, nil
 }
 
@@ -104,7 +350,7 @@ func (p *Parser) parseRead(args []string, lineNum int) (Command, error) {
 		return Command{}, err
 	}
 
-	offsetB, err := p.parseNumber(args[1])
+	offsetB, ref, err := p.parseOperand(args[1], FieldB)
 	if err != nil {
 		return Command{}, err
 	}
@@ -116,13 +362,14 @@ func (p *Parser) parseRead(args []string, lineNum int) (Command, error) {
 
 	return Command{
 		Type: READ_MEM,
-		Fields: map[string]uint32{
-			"A": 8, // Код операции
-			"B": offsetB,
-			"C": regC,
-			"D": regD,
+		Fields: Fields{
+			FieldA: 8, // Код операции
+			FieldB: offsetB,
+			FieldC: regC,
+			FieldD: regD,
 		},
 		Line: lineNum,
+		Ref:  ref,
 	}, nil
 }
 
@@ -144,10 +391,10 @@ func (p *Parser) parseWrite(args []string, lineNum int) (Command, error) {
 
 	return Command{
 		Type: WRITE_MEM,
-		Fields: map[string]uint32{
-			"A": 37, // Код операции
-			"B": regB,
-			"C": regC,
+		Fields: Fields{
+			FieldA: 37, // Код операции
+			FieldB: regB,
+			FieldC: regC,
 		},
 		Line: lineNum,
 	}, nil
@@ -164,19 +411,20 @@ func (p *Parser) parseSqrt(args []string, lineNum int) (Command, error) {
 		return Command{}, err
 	}
 
-	addrC, err := p.parseNumber(args[1])
+	addrC, ref, err := p.parseOperand(args[1], FieldC)
 	if err != nil {
 		return Command{}, err
 	}
 
 	return Command{
 		Type: SQRT_OP,
-		Fields: map[string]uint32{
-			"A": 4, // Код операции
-			"B": regB,
-			"C": addrC,
+		Fields: Fields{
+			FieldA: 4, // Код операции
+			FieldB: regB,
+			FieldC: addrC,
 		},
 		Line: lineNum,
+		Ref:  ref,
 	}, nil
 }
 
@@ -214,3 +462,67 @@ func (p *Parser) parseNumber(s string) (uint32, error) {
 
 	return 0, fmt.Errorf("неверный числовой формат: %s", s)
 }
+
+// parseCount разбирает количество для .space: неотрицательное число или константу .equ,
+// определенную выше
+func (p *Parser) parseCount(s string) (uint32, error) {
+	if sym, ok := p.program.Symbols[s]; ok && sym.Section == SectionAbsolute && sym.Line != 0 {
+		return sym.Value, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("количество слов не может быть отрицательным: %s", s)
+	}
+	return p.parseNumber(s)
+}
+
+// parseOperand разбирает числовой операнд: число, символ или символ со смещением (buf+2)
+func (p *Parser) parseOperand(s string, field Field) (uint32, *SymbolRef, error) {
+	if val, err := p.parseNumber(s); err == nil {
+		return val, nil, nil
+	}
+
+	name, addend := s, 0
+	if idx := strings.LastIndexAny(s, "+-"); idx > 0 {
+		val, err := strconv.Atoi(s[idx:])
+		if err != nil {
+			return 0, nil, fmt.Errorf("неверное смещение символа: %s", s)
+		}
+		name, addend = s[:idx], val
+	}
+
+	if !isIdentifier(name) {
+		return 0, nil, fmt.Errorf("неверный числовой формат: %s", s)
+	}
+
+	return 0, &SymbolRef{Field: field, Symbol: name, Addend: int32(addend), Line: p.currentLine}, nil
+}
+
+// isIdentifier проверяет, может ли строка быть именем символа.
+// Имена вида R0-R63 зарезервированы за регистрами.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		letter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		digit := r >= '0' && r <= '9'
+		if !letter && !(digit && i > 0) {
+			return false
+		}
+	}
+	if s[0] == 'R' && len(s) > 1 {
+		if _, err := strconv.Atoi(s[1:]); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// columnOf возвращает номер символа (с 1), с которого text начинается в строке
+func columnOf(raw, text string) int {
+	idx := strings.Index(raw, text)
+	if idx < 0 {
+		return 1
+	}
+	return utf8.RuneCountInString(raw[:idx]) + 1
+}
@@ -0,0 +1,67 @@
+package assembler
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// mixSource возвращает случайную смесь всех четырех команд из n строк
+func mixSource(n int) string {
+	rng := rand.New(rand.NewSource(1))
+	reg := func() int { return rng.Intn(64) }
+
+	var src strings.Builder
+	for i := 0; i < n; i++ {
+		switch rng.Intn(4) {
+		case 0:
+			fmt.Fprintf(&src, "LOAD R%d %d\n", reg(), rng.Intn(1<<24))
+		case 1:
+			fmt.Fprintf(&src, "READ R%d %d R%d\n", reg(), rng.Intn(1<<16), reg())
+		case 2:
+			fmt.Fprintf(&src, "WRITE R%d R%d\n", reg(), reg())
+		default:
+			fmt.Fprintf(&src, "SQRT R%d %d\n", reg(), rng.Intn(1<<24))
+		}
+	}
+	return src.String()
+}
+
+func TestSpaceLimits(t *testing.T) {
+	for _, source := range []string{
+		".data\n.space -1\n",
+		fmt.Sprintf(".data\n.space %d\n", MaxDataWords+1),
+		fmt.Sprintf(".data\n.space %d\n.word 1\n", MaxDataWords),
+	} {
+		if _, err := NewParser(source).ParseProgram(); err == nil {
+			t.Errorf("%q: ожидалась ошибка", source)
+		}
+	}
+
+	program, err := NewParser(".equ SIZE, 3\n.data\nbuf: .space SIZE\n.word 7\n").ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Data) != 4 || program.Data[3] != 7 {
+		t.Errorf("данные %v, ожидалось [0 0 0 7]", program.Data)
+	}
+}
+
+// BenchmarkParseEncode измеряет разбор и кодирование программы; время
+// и выделения памяти на команду - ns/op и allocs/op, деленные на число строк
+func BenchmarkParseEncode(b *testing.B) {
+	const n = 4096
+	source := mixSource(n)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(source)))
+	for i := 0; i < b.N; i++ {
+		program, err := NewParser(source).ParseProgram()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := program.Image(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package assembler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Resolve подставляет значения символов для программы из одного файла:
+// метки кода получают номера команд, метки данных - адреса слов начиная с 0.
+// Возвращает команды с заполненными полями и начальные данные.
+func (prog *Program) Resolve() ([]Command, []uint32, error) {
+	commands := make([]Command, len(prog.Commands))
+	for i, cmd := range prog.Commands {
+		commands[i] = cmd
+		if cmd.Ref == nil {
+			continue
+		}
+
+		value, err := prog.refValue(cmd.Ref)
+		if err != nil {
+			return nil, nil, lineError(cmd.Line, err)
+		}
+		commands[i].Fields[cmd.Ref.Field] = value
+	}
+
+	data := append([]uint32(nil), prog.Data...)
+	for i, ref := range prog.DataRefs {
+		value, err := prog.refValue(ref)
+		if err != nil {
+			return nil, nil, lineError(ref.Line, err)
+		}
+		data[i] = value
+	}
+
+	return commands, data, nil
+}
+
+// Image собирает программу из одного файла в образ: код и секцию данных
+func (prog *Program) Image() (*Image, error) {
+	commands, data, err := prog.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := NewEncoder()
+	code := make([]byte, 0, len(commands)*CommandSize)
+	for _, cmd := range commands {
+		machineCode, err := encoder.Encode(cmd)
+		if err != nil {
+			return nil, lineError(cmd.Line, err)
+		}
+		code = append(code, machineCode...)
+	}
+
+	img := NewImage(code)
+	if len(data) > 0 {
+		img.Sections = append(img.Sections, Section{Kind: SectionData, Data: EncodeWords(data)})
+	}
+	return img, nil
+}
+
+// refValue вычисляет значение ссылки на символ, определенный в этом файле
+func (prog *Program) refValue(ref *SymbolRef) (uint32, error) {
+	sym := prog.Symbols[ref.Symbol]
+	if sym == nil || sym.Extern {
+		return 0, fmt.Errorf("внешний символ %s не разрешен: соберите объектный файл (-object) и используйте link", ref.Symbol)
+	}
+	return ApplyAddend(sym.Value, ref.Addend)
+}
+
+// SortedSymbols возвращает символы программы, упорядоченные по имени
+func (prog *Program) SortedSymbols() []*Symbol {
+	result := make([]*Symbol, 0, len(prog.Symbols))
+	for _, sym := range prog.Symbols {
+		result = append(result, sym)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// ApplyAddend прибавляет смещение к значению символа
+func ApplyAddend(value uint32, addend int32) (uint32, error) {
+	result := int64(value) + int64(addend)
+	if result < 0 || result > int64(^uint32(0)) {
+		return 0, fmt.Errorf("значение %d%+d выходит за пределы 32 бит", value, addend)
+	}
+	return uint32(result), nil
+}
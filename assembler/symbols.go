@@ -0,0 +1,110 @@
+package assembler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SymbolFileVersion - версия формата файла символов
+const SymbolFileVersion = 1
+
+// SymbolEntry - символ с окончательным адресом в программе
+type SymbolEntry struct {
+	Name    string `json:"name"`
+	Section string `json:"section"` // code, data или abs
+	Address uint32 `json:"address"` // номер команды, адрес слова данных или значение константы
+	Size    uint32 `json:"size"`    // в командах или словах данных
+	Global  bool   `json:"global"`
+	Object  string `json:"object,omitempty"`
+}
+
+// SymbolTable - машиночитаемый файл символов программы (JSON), который загружают
+// дизассемблер и эмулятор, чтобы показывать имена вместо адресов
+type SymbolTable struct {
+	Version int           `json:"version"`
+	Entry   uint32        `json:"entry"`
+	Symbols []SymbolEntry `json:"symbols"`
+}
+
+// LoadSymbolTable читает файл символов
+func LoadSymbolTable(path string) (*SymbolTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table SymbolTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if table.Version != SymbolFileVersion {
+		return nil, fmt.Errorf("%s: неподдерживаемая версия файла символов: %d", path, table.Version)
+	}
+	return &table, nil
+}
+
+// Save записывает файл символов
+func (t *SymbolTable) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Find ищет символ по имени
+func (t *SymbolTable) Find(name string) (SymbolEntry, bool) {
+	for _, sym := range t.Symbols {
+		if sym.Name == name {
+			return sym, true
+		}
+	}
+	return SymbolEntry{}, false
+}
+
+// Describe возвращает имя адреса в виде символ или символ+смещение.
+// Если адрес не попадает ни в один символ секции, возвращается пустая строка.
+func (t *SymbolTable) Describe(section SectionKind, addr uint32) string {
+	if t == nil {
+		return ""
+	}
+
+	var best *SymbolEntry
+	for i := range t.Symbols {
+		sym := &t.Symbols[i]
+		if sym.Section != section.String() || sym.Address > addr {
+			continue
+		}
+		if addr >= sym.Address+max(sym.Size, 1) {
+			continue
+		}
+		if best == nil || sym.Address > best.Address || (sym.Address == best.Address && sym.Global && !best.Global) {
+			best = sym
+		}
+	}
+
+	switch {
+	case best == nil:
+		return ""
+	case best.Address == addr:
+		return best.Name
+	default:
+		return fmt.Sprintf("%s+%d", best.Name, addr-best.Address)
+	}
+}
+
+// SortSymbols упорядочивает символы по секции, адресу и имени
+func SortSymbols(symbols []SymbolEntry) {
+	sort.Slice(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if a.Section != b.Section {
+			return a.Section < b.Section
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.Name < b.Name
+	})
+}
@@ -11,12 +11,65 @@ const (
 	SQRT_OP    CommandType = 4
 )
 
+// Field - поле команды: A - код операции, B, C и D - операнды (см. Layout)
+type Field uint8
+
+const (
+	FieldA Field = iota
+	FieldB
+	FieldC
+	FieldD
+	FieldCount
+
+	FieldNone Field = 0xFF // нет поля: ссылка из слова данных
+)
+
+func (f Field) String() string {
+	if f < FieldCount {
+		return string(rune('A' + f))
+	}
+	return ""
+}
+
+// Fields - значения полей команды по номеру поля. Поля, которых нет
+// в формате команды, равны 0.
+type Fields [FieldCount]uint32
+
 type Command struct {
 	Type   CommandType
-	Fields map[string]uint32
+	Fields Fields
+	Line   int
+	Column int
+	Ref    *SymbolRef // поле, значение которого задано символом
+}
+
+// SymbolRef - ссылка на символ со смещением (метка+4)
+type SymbolRef struct {
+	Field  Field // поле команды; FieldNone для слова данных
+	Symbol string
+	Addend int32
 	Line   int
 }
 
+// Symbol - метка, константа .equ или внешний символ
+type Symbol struct {
+	Name    string
+	Section SectionKind // SectionCode, SectionData или SectionAbsolute для .equ
+	Value   uint32      // номер команды, адрес слова данных или значение константы
+	Global  bool
+	Extern  bool
+	Line    int
+}
+
+// Program - результат разбора исходного файла до разрешения символов
+type Program struct {
+	File     string
+	Commands []Command
+	Data     []uint32
+	DataRefs map[int]*SymbolRef // слова данных, заданные символами
+	Symbols  map[string]*Symbol
+}
+
 func (ct CommandType) TypeName() string {
 	names := map[CommandType]string{
 		LOAD_CONST: "LOAD",
@@ -31,6 +84,8 @@ type Parser struct {
 	Lines       []string
 	currentLine int
 	filename    string
+	section     SectionKind
+	program     *Program
 }
 
 //Для 1 этапа
@@ -39,11 +94,11 @@ type Parser struct {
 func (c Command) String() string {
 	switch c.Type {
 	case LOAD_CONST:
-		return fmt.Sprintf("A=%d, B=%d, C=%d", c.Fields["A"], c.Fields["B"], c.Fields["C"])
+		return fmt.Sprintf("A=%d, B=%d, C=%d", c.Fields[FieldA], c.Fields[FieldB], c.Fields[FieldC])
 	case READ_MEM:
-		return fmt.Sprintf("A=%d, B=%d, C=%d, D=%d", c.Fields["A"], c.Fields["B"], c.Fields["C"], c.Fields["D"])
+		return fmt.Sprintf("A=%d, B=%d, C=%d, D=%d", c.Fields[FieldA], c.Fields[FieldB], c.Fields[FieldC], c.Fields[FieldD])
 	case WRITE_MEM, SQRT_OP:
-		return fmt.Sprintf("A=%d, B=%d, C=%d", c.Fields["A"], c.Fields["B"], c.Fields["C"])
+		return fmt.Sprintf("A=%d, B=%d, C=%d", c.Fields[FieldA], c.Fields[FieldB], c.Fields[FieldC])
 	default:
 		return "Неизвестная команда"
 	}
@@ -53,12 +108,22 @@ func (c Command) String() string {
 func (c Command) ToTestFormat() string {
 	switch c.Type {
 	case LOAD_CONST:
-		return fmt.Sprintf("(A=%d, B=%d, C=%d)", c.Fields["A"], c.Fields["B"], c.Fields["C"])
+		return fmt.Sprintf("(A=%d, B=%d, C=%d)", c.Fields[FieldA], c.Fields[FieldB], c.Fields[FieldC])
 	case READ_MEM:
-		return fmt.Sprintf("(A=%d, B=%d, C=%d, D=%d)", c.Fields["A"], c.Fields["B"], c.Fields["C"], c.Fields["D"])
+		return fmt.Sprintf("(A=%d, B=%d, C=%d, D=%d)", c.Fields[FieldA], c.Fields[FieldB], c.Fields[FieldC], c.Fields[FieldD])
 	case WRITE_MEM, SQRT_OP:
-		return fmt.Sprintf("(A=%d, B=%d, C=%d)", c.Fields["A"], c.Fields["B"], c.Fields["C"])
+		return fmt.Sprintf("(A=%d, B=%d, C=%d)", c.Fields[FieldA], c.Fields[FieldB], c.Fields[FieldC])
 	default:
 		return "(Неизвестная команда)"
 	}
-}
\ No newline at end of file
+}
+
+// FieldMap возвращает поля формата команды по именам (для JSON и подробного вывода)
+func (c Command) FieldMap() map[string]uint32 {
+	layout, _ := Layout(c.Type)
+	result := make(map[string]uint32, len(layout))
+	for _, f := range layout {
+		result[f.Field.String()] = c.Fields[f.Field]
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package assembler
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// specVectors - тестовые случаи спецификации из test_files/specification_tests.asm
+var specVectors = []struct {
+	source string
+	str    string
+	test   string
+	code   []byte
+}{
+	{"LOAD R9 771", "A=59, B=9, C=771", "(A=59, B=9, C=771)", []byte{0x7B, 0x32, 0x30, 0x00, 0x00}},
+	{"READ R35 499 R42", "A=8, B=499, C=42, D=35", "(A=8, B=499, C=42, D=35)", []byte{0xC8, 0x7C, 0x80, 0x3A, 0x02}},
+	{"WRITE R25 R3", "A=37, B=25, C=3", "(A=37, B=25, C=3)", []byte{0x65, 0x36, 0x00, 0x00, 0x00}},
+	{"SQRT R9 804", "A=4, B=9, C=804", "(A=4, B=9, C=804)", []byte{0x44, 0x42, 0x32, 0x00, 0x00}},
+}
+
+func TestSpecificationVectors(t *testing.T) {
+	source, err := os.ReadFile("../test_files/specification_tests.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands, err := NewParser(string(source)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != len(specVectors) {
+		t.Fatalf("разобрано %d команд, ожидалось %d", len(commands), len(specVectors))
+	}
+
+	for i, v := range specVectors {
+		cmd := commands[i]
+		if got := cmd.String(); got != v.str {
+			t.Errorf("%s: String() = %q, ожидалось %q", v.source, got, v.str)
+		}
+		if got := cmd.ToTestFormat(); got != v.test {
+			t.Errorf("%s: ToTestFormat() = %q, ожидалось %q", v.source, got, v.test)
+		}
+
+		code, err := NewEncoder().Encode(cmd)
+		if err != nil {
+			t.Fatalf("%s: %v", v.source, err)
+		}
+		if !bytes.Equal(code, v.code) {
+			t.Errorf("%s: байты % X, ожидалось % X", v.source, code, v.code)
+		}
+
+		decoded, err := Decode(code)
+		if err != nil {
+			t.Fatalf("%s: %v", v.source, err)
+		}
+		if decoded.Type != cmd.Type || decoded.Fields != cmd.Fields {
+			t.Errorf("%s: декодировано %s, ожидалось %s", v.source, decoded, cmd)
+		}
+		if got := Disassemble(decoded); got != v.source {
+			t.Errorf("Disassemble = %q, ожидалось %q", got, v.source)
+		}
+	}
+}
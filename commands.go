@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"uvm-assembler/assembler"
+	"uvm-assembler/emulator"
+)
+
+// subcommands - дополнительные команды, вызываемые как uvm-assembler <команда> ...
+var subcommands = map[string]func(args []string){
+	"info":  runInfo,
+	"link":  runLink,
+	"ar":    runArchive,
+	"debug": runDebug,
+	"run":   runRun,
+	"gdb":   runGDB,
+	"dap":   runDAP,
+	"lsp":   runLSP,
+	"sqrt":  runSqrt,
+}
+
+// runInfo проверяет двоичный файл и выводит его заголовок и секции
+func runInfo(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler info program.bin")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("❌ Ошибка чтения файла: %v\n", err)
+		os.Exit(1)
+	}
+
+	img, err := assembler.LoadImage(data)
+	if err != nil {
+		fmt.Printf("❌ Файл %s поврежден: %v\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Файл: %s (%d байт)\n", fs.Arg(0), len(data))
+	if img.Raw {
+		fmt.Println("Формат: сырой (без заголовка)")
+	} else {
+		fmt.Printf("Формат: контейнер v%d, система команд v%d\n", img.Version, img.ISAVersion)
+		fmt.Println("Контрольная сумма: ✅ совпадает")
+	}
+	fmt.Printf("Точка входа: %d\n", img.Entry)
+	fmt.Println("Секции:")
+	for _, s := range img.Sections {
+		fmt.Printf("  %-5s адрес=%d размер=%d байт\n", s.Kind, s.Addr, len(s.Data))
+	}
+}
+
+// runSqrt вычисляет SQRT в выбранном режиме или проверяет эталонные значения
+func runSqrt(args []string) {
+	fs := flag.NewFlagSet("sqrt", flag.ExitOnError)
+	sqrtOptions := sqrtFlags(fs)
+	vectors := fs.Bool("vectors", false, "Проверить эталонные значения всех режимов")
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler sqrt [-sqrt-mode floor|round|q16|float32] [-sqrt-signed] значение...")
+		fmt.Println("               uvm-assembler sqrt -vectors")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *vectors {
+		if !checkSqrtVectors() {
+			os.Exit(1)
+		}
+		return
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	mode, signed := sqrtOptions()
+	for _, arg := range fs.Args() {
+		x, err := parseSqrtArg(mode, arg)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		result, err := emulator.Sqrt(mode, signed, x)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(emulator.FaultSqrt.ExitCode())
+		}
+		fmt.Printf("sqrt(%s) = %s\n", formatSqrtValue(mode, x), formatSqrtValue(mode, result))
+	}
+}
+
+// parseSqrtArg разбирает аргумент. Запись 0x... - биты регистра в любом режиме;
+// в режимах q16 и float32 иначе задается число (в том числе дробное), в целых
+// режимах - целое число, возможно отрицательное.
+func parseSqrtArg(mode emulator.SqrtMode, arg string) (uint32, error) {
+	if mode == emulator.SqrtFloat || mode == emulator.SqrtQ16 {
+		if !strings.HasPrefix(arg, "0x") && !strings.HasPrefix(arg, "0X") {
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return 0, fmt.Errorf("неверное значение: %s", arg)
+			}
+			if mode == emulator.SqrtFloat {
+				return math.Float32bits(float32(f)), nil
+			}
+			return uint32(int32(f * 65536)), nil
+		}
+	}
+	if v, err := strconv.ParseUint(arg, 0, 32); err == nil {
+		return uint32(v), nil
+	}
+	if v, err := strconv.ParseInt(arg, 0, 32); err == nil {
+		return uint32(v), nil
+	}
+	return 0, fmt.Errorf("неверное значение: %s", arg)
+}
+
+// formatSqrtValue показывает значение регистра и его смысл в данном режиме
+func formatSqrtValue(mode emulator.SqrtMode, x uint32) string {
+	switch mode {
+	case emulator.SqrtFloat:
+		return fmt.Sprintf("0x%08X (%g)", x, math.Float32frombits(x))
+	case emulator.SqrtQ16:
+		return fmt.Sprintf("0x%08X (%g)", x, float64(x)/65536)
+	}
+	return fmt.Sprintf("%d", x)
+}
+
+// checkSqrtVectors проверяет реализацию SQRT на эталонных значениях
+func checkSqrtVectors() bool {
+	failed := 0
+	for _, v := range emulator.SqrtVectors {
+		got, err := emulator.Sqrt(v.Mode, v.Signed, v.In)
+		want := fmt.Sprintf("0x%08X", v.Out)
+		if v.Fault {
+			want = "ошибка"
+		}
+		result := fmt.Sprintf("0x%08X", got)
+		if err != nil {
+			result = "ошибка"
+		}
+
+		mark := "✅"
+		if result != want {
+			mark = "❌"
+			failed++
+		}
+		signed := ""
+		if v.Signed {
+			signed = ", signed"
+		}
+		fmt.Printf("%s %-8s sqrt(0x%08X%s) = %s, ожидалось %s\n", mark, v.Mode, v.In, signed, result, want)
+	}
+
+	if failed > 0 {
+		fmt.Printf("❌ Не совпало %d из %d значений\n", failed, len(emulator.SqrtVectors))
+		return false
+	}
+	fmt.Printf("✅ Все %d значений совпали\n", len(emulator.SqrtVectors))
+	return true
+}
@@ -0,0 +1,110 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Сообщения Debug Adapter Protocol передаются как JSON с заголовком
+// "Content-Length: N\r\n\r\n".
+
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type response struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+// readRequest читает одно сообщение клиента
+func readRequest(r *bufio.Reader) (*request, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("неверный заголовок: %s", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("сообщение без заголовка Content-Length")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("неверное сообщение: %v", err)
+	}
+	return &req, nil
+}
+
+// write отправляет сообщение клиенту, назначая ему очередной номер
+func (s *Server) write(msg any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		s.logf("ошибка кодирования сообщения: %v", err)
+		return
+	}
+	s.logf("<- %s", data)
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *Server) respond(req *request, body any) {
+	s.write(&response{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *Server) fail(req *request, err error) {
+	s.write(&response{
+		Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: err.Error(),
+		Body: map[string]any{"error": map[string]any{"id": 1, "format": err.Error()}},
+	})
+}
+
+func (s *Server) sendEvent(name string, body any) {
+	s.write(&event{Type: "event", Event: name, Body: body})
+}
@@ -0,0 +1,751 @@
+// Package dap реализует сервер Debug Adapter Protocol для УВМ, чтобы отлаживать
+// программы на ассемблере из редакторов (VS Code и других клиентов DAP).
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"uvm-assembler/assembler"
+	"uvm-assembler/debugger"
+	"uvm-assembler/emulator"
+)
+
+const (
+	threadID     = 1
+	pollInterval = 1024 // через сколько команд выполнение проверяет запросы клиента
+
+	// Ссылки на наборы переменных
+	registersRef  = 1
+	symbolsRef    = 2
+	memoryRef     = 3
+	memoryPageRef = 1000 // + номер страницы памяти
+	pageWords     = 16
+	maxPages      = 256 // страниц в списке памяти
+)
+
+// dataSymbol - метка данных, показываемая как переменная
+type dataSymbol struct {
+	name string
+	addr uint32
+}
+
+// Server - сервер DAP для одного сеанса отладки
+type Server struct {
+	in  io.Reader
+	out io.Writer
+	mu  sync.Mutex
+	seq int
+	Log io.Writer // необязательный журнал сообщений
+
+	requests chan *request
+	pending  func() // действие после отправки ответа, например запуск выполнения
+	done     bool
+
+	vm          *emulator.VM
+	history     *emulator.Recorder
+	debug       *assembler.DebugInfo
+	symbols     map[string]uint32
+	dataSymbols []dataSymbol
+	programDir  string
+	stopOnEntry bool
+	noDebug     bool
+
+	breakpoints map[string]map[uint32]int // файл -> адрес команды -> номер точки
+	nextID      int
+}
+
+// NewServer создает сервер, читающий запросы из in и пишущий ответы в out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:          in,
+		out:         out,
+		symbols:     map[string]uint32{},
+		breakpoints: map[string]map[uint32]int{},
+		nextID:      1,
+	}
+}
+
+var handlers map[string]func(s *Server, req *request) (any, error)
+
+func init() {
+	handlers = map[string]func(s *Server, req *request) (any, error){
+		"initialize":              (*Server).initialize,
+		"launch":                  (*Server).launch,
+		"setBreakpoints":          (*Server).setBreakpoints,
+		"setExceptionBreakpoints": func(*Server, *request) (any, error) { return nil, nil },
+		"configurationDone":       (*Server).configurationDone,
+		"threads":                 (*Server).threads,
+		"stackTrace":              (*Server).stackTrace,
+		"scopes":                  (*Server).scopes,
+		"variables":               (*Server).variables,
+		"evaluate":                (*Server).evaluate,
+		"continue":                (*Server).cont,
+		"next":                    (*Server).next,
+		"stepIn":                  (*Server).next,
+		"stepOut":                 (*Server).stepOut,
+		"stepBack":                (*Server).stepBack,
+		"reverseContinue":         (*Server).reverseContinue,
+		"pause":                   (*Server).pause,
+		"disconnect":              (*Server).disconnect,
+		"terminate":               (*Server).terminate,
+	}
+}
+
+// Run обрабатывает запросы до команды disconnect или конца ввода
+func (s *Server) Run() error {
+	s.requests = make(chan *request, 16)
+	readErr := make(chan error, 1)
+	go func() {
+		br := bufio.NewReader(s.in)
+		for {
+			req, err := readRequest(br)
+			if err != nil {
+				readErr <- err
+				close(s.requests)
+				return
+			}
+			s.requests <- req
+		}
+	}()
+
+	for req := range s.requests {
+		s.dispatch(req)
+		if s.done {
+			return nil
+		}
+	}
+	if err := <-readErr; err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// dispatch выполняет запрос, отправляет ответ и затем отложенное действие
+func (s *Server) dispatch(req *request) {
+	s.logf("-> %s %s", req.Command, req.Arguments)
+	handler, ok := handlers[req.Command]
+	if !ok {
+		s.fail(req, fmt.Errorf("запрос не поддерживается: %s", req.Command))
+		return
+	}
+
+	body, err := handler(s, req)
+	if err != nil {
+		s.pending = nil
+		s.fail(req, err)
+		return
+	}
+	s.respond(req, body)
+
+	if action := s.pending; action != nil {
+		s.pending = nil
+		action()
+	}
+}
+
+func (s *Server) initialize(req *request) (any, error) {
+	return map[string]any{
+		"supportsConfigurationDoneRequest": true,
+		"supportsEvaluateForHovers":        true,
+		"supportsStepBack":                 true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+type launchArguments struct {
+	Program     string `json:"program"`
+	Symbols     string `json:"symbols"`
+	Memory      int    `json:"memory"`
+	WordBits    uint   `json:"wordBits"`
+	Addressing  string `json:"addressing"`
+	OutOfBounds string `json:"outOfBounds"`
+	SqrtMode    string `json:"sqrtMode"`
+	SqrtSigned  bool   `json:"sqrtSigned"`
+	NoDevices   bool   `json:"noDevices"`
+	History     int    `json:"history"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+	NoDebug     bool   `json:"noDebug"`
+}
+
+// launch загружает программу: исходный текст .asm собирается в памяти,
+// двоичный файл загружается вместе с <program>.dbg
+func (s *Server) launch(req *request) (any, error) {
+	var args launchArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.Program == "" {
+		return nil, fmt.Errorf("не задан параметр program")
+	}
+
+	path, err := filepath.Abs(args.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	var img *assembler.Image
+	if strings.EqualFold(filepath.Ext(path), ".asm") {
+		img, err = s.assemble(path)
+	} else {
+		img, err = s.loadBinary(path, args.Symbols)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	model := emulator.MemoryModel{
+		Size:        args.Memory,
+		WordBits:    args.WordBits,
+		Addressing:  emulator.Addressing(args.Addressing),
+		OutOfBounds: emulator.BoundsPolicy(args.OutOfBounds),
+	}
+	sqrtMode := emulator.SqrtFloor
+	if args.SqrtMode != "" {
+		if sqrtMode, err = emulator.ParseSqrtMode(args.SqrtMode); err != nil {
+			return nil, err
+		}
+	}
+	if s.vm, err = emulator.NewWithModel(img, model); err != nil {
+		return nil, err
+	}
+	s.vm.Debug = s.debug
+	s.vm.SqrtMode, s.vm.SqrtSigned = sqrtMode, args.SqrtSigned
+	if !args.NoDevices {
+		// Вывод программы передается клиенту событиями output, ввод всегда пуст
+		if _, err := s.vm.AttachStandardDevices(emulator.DefaultDeviceBase, bytes.NewReader(nil), programOutput{s}); err != nil {
+			return nil, err
+		}
+	}
+	s.history = emulator.NewRecorder(s.vm)
+	if args.History > 0 {
+		s.history.Limit = args.History
+	}
+
+	s.programDir = filepath.Dir(path)
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+	sort.Slice(s.dataSymbols, func(i, j int) bool { return s.dataSymbols[i].addr < s.dataSymbols[j].addr })
+
+	// Точки останова принимаются только после загрузки программы
+	s.pending = func() { s.sendEvent("initialized", nil) }
+	return nil, nil
+}
+
+// assemble собирает исходный текст в образ и таблицу строк
+func (s *Server) assemble(path string) (*assembler.Image, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := assembler.NewParser(string(content))
+	parser.SetFilename(path)
+	program, err := parser.ParseProgram()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	img, err := program.Image()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+
+	s.debug = assembler.NewDebugInfo(program.LineTable(0))
+	for _, sym := range program.SortedSymbols() {
+		if sym.Extern {
+			continue
+		}
+		s.symbols[sym.Name] = sym.Value
+		if sym.Section == assembler.SectionData {
+			s.dataSymbols = append(s.dataSymbols, dataSymbol{sym.Name, sym.Value})
+		}
+	}
+	return img, nil
+}
+
+// loadBinary загружает двоичную программу, отладочную информацию и символы
+func (s *Server) loadBinary(path, symbolsFile string) (*assembler.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := assembler.LoadImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+
+	if _, err := os.Stat(assembler.DebugInfoPath(path)); err == nil {
+		if s.debug, err = assembler.LoadDebugInfo(assembler.DebugInfoPath(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	if symbolsFile != "" {
+		table, err := assembler.LoadSymbolTable(symbolsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, sym := range table.Symbols {
+			s.symbols[sym.Name] = sym.Address
+			if sym.Section == assembler.SectionData.String() {
+				s.dataSymbols = append(s.dataSymbols, dataSymbol{sym.Name, sym.Address})
+			}
+		}
+	}
+	return img, nil
+}
+
+type sourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+type setBreakpointsArguments struct {
+	Source struct {
+		Path string `json:"path"`
+	} `json:"source"`
+	Breakpoints []sourceBreakpoint `json:"breakpoints"`
+	Lines       []int              `json:"lines"` // устаревшая форма
+}
+
+// setBreakpoints заменяет точки останова файла. Точка на строке без команд
+// переносится на ближайшую следующую строку с командами.
+func (s *Server) setBreakpoints(req *request) (any, error) {
+	var args setBreakpointsArguments
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, err
+	}
+	if len(args.Breakpoints) == 0 {
+		for _, line := range args.Lines {
+			args.Breakpoints = append(args.Breakpoints, sourceBreakpoint{Line: line})
+		}
+	}
+
+	file := args.Source.Path
+	addrs := map[uint32]int{}
+	result := []map[string]any{}
+	for _, bp := range args.Breakpoints {
+		line, found := s.codeLine(file, bp.Line)
+		if found == nil {
+			result = append(result, map[string]any{
+				"verified": false, "line": bp.Line, "message": "На этой строке и ниже нет команд",
+			})
+			continue
+		}
+
+		id := s.nextID
+		s.nextID++
+		for _, addr := range found {
+			addrs[addr] = id
+		}
+		result = append(result, map[string]any{"id": id, "verified": true, "line": line})
+	}
+
+	s.breakpoints[file] = addrs
+	return map[string]any{"breakpoints": result}, nil
+}
+
+// codeLine находит первую строку не раньше line, для которой есть команды
+func (s *Server) codeLine(file string, line int) (int, []uint32) {
+	if s.debug == nil || len(s.debug.Lines) == 0 {
+		return 0, nil
+	}
+	last := 0
+	for _, entry := range s.debug.Lines {
+		last = max(last, entry.Line)
+	}
+	for ; line <= last; line++ {
+		if addrs := s.debug.Addresses(file, line); len(addrs) > 0 {
+			return line, addrs
+		}
+	}
+	return 0, nil
+}
+
+func (s *Server) hasBreakpoint(addr uint32) bool {
+	if s.noDebug {
+		return false
+	}
+	for _, addrs := range s.breakpoints {
+		if _, ok := addrs[addr]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) configurationDone(req *request) (any, error) {
+	if s.vm == nil {
+		return nil, fmt.Errorf("программа не загружена: ожидается запрос launch")
+	}
+	s.pending = func() {
+		switch {
+		case s.vm.Halted:
+		case s.stopOnEntry && !s.noDebug:
+			s.stopped("entry", "")
+			return
+		case s.hasBreakpoint(s.vm.PC):
+			// run проверяет точки после шага, поэтому точка на первой команде - здесь
+			s.stopped("breakpoint", "")
+			return
+		}
+		s.run(0)
+	}
+	return nil, nil
+}
+
+func (s *Server) threads(req *request) (any, error) {
+	return map[string]any{"threads": []map[string]any{{"id": threadID, "name": "УВМ"}}}, nil
+}
+
+// stackTrace возвращает единственный кадр: текущую команду и ее строку
+func (s *Server) stackTrace(req *request) (any, error) {
+	if s.vm == nil {
+		return map[string]any{"stackFrames": []any{}, "totalFrames": 0}, nil
+	}
+
+	name := "<программа завершена>"
+	if cmd, err := s.vm.Fetch(s.vm.PC); err == nil {
+		name = fmt.Sprintf("%d: %s", s.vm.PC, assembler.Disassemble(cmd))
+	}
+	frame := map[string]any{
+		"id": 1, "name": name, "line": 0, "column": 0,
+		"instructionPointerReference": fmt.Sprint(s.vm.PC),
+	}
+	if loc, ok := s.debug.Lookup(s.vm.PC); ok {
+		path := s.sourcePath(loc.File)
+		frame["source"] = map[string]any{"name": filepath.Base(path), "path": path}
+		frame["line"] = loc.Line
+		frame["column"] = loc.Column
+	}
+	return map[string]any{"stackFrames": []any{frame}, "totalFrames": 1}, nil
+}
+
+// sourcePath переводит путь из отладочной информации в абсолютный;
+// относительные пути отсчитываются от каталога программы
+func (s *Server) sourcePath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	if _, err := os.Stat(file); err == nil {
+		if abs, err := filepath.Abs(file); err == nil {
+			return abs
+		}
+	}
+	return filepath.Join(s.programDir, file)
+}
+
+func (s *Server) scopes(req *request) (any, error) {
+	scopes := []map[string]any{
+		{"name": "Регистры", "variablesReference": registersRef, "expensive": false},
+	}
+	if len(s.dataSymbols) > 0 {
+		scopes = append(scopes, map[string]any{"name": "Данные", "variablesReference": symbolsRef, "expensive": false})
+	}
+	scopes = append(scopes, map[string]any{"name": "Память", "variablesReference": memoryRef, "expensive": true})
+	return map[string]any{"scopes": scopes}, nil
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// variables возвращает регистры, метки данных, страницы памяти или слова страницы
+func (s *Server) variables(req *request) (any, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, err
+	}
+	if s.vm == nil {
+		return nil, fmt.Errorf("программа не загружена")
+	}
+
+	vars := []variable{}
+	switch ref := args.VariablesReference; {
+	case ref == registersRef:
+		vars = append(vars,
+			variable{Name: "PC", Value: fmt.Sprint(s.vm.PC), Type: "uint32"},
+			variable{Name: "шаг", Value: fmt.Sprint(s.vm.Steps), Type: "uint64"})
+		for i, value := range s.vm.Regs {
+			vars = append(vars, variable{Name: fmt.Sprintf("R%d", i), Value: fmt.Sprint(value), Type: "uint32"})
+		}
+	case ref == symbolsRef:
+		for _, sym := range s.dataSymbols {
+			value, err := s.vm.ReadMemory(sym.addr)
+			text := fmt.Sprint(value)
+			if err != nil {
+				text = err.Error()
+			}
+			vars = append(vars, variable{Name: fmt.Sprintf("%s [%d]", sym.name, sym.addr), Value: text, Type: "uint32"})
+		}
+	case ref == memoryRef:
+		for page := 0; page*pageWords < len(s.vm.Memory) && len(vars) < maxPages; page++ {
+			words := s.page(page)
+			if isZero(words) {
+				continue
+			}
+			vars = append(vars, variable{
+				Name:               fmt.Sprintf("[%d..%d]", page*pageWords, page*pageWords+len(words)-1),
+				Value:              formatWords(words),
+				VariablesReference: memoryPageRef + page,
+			})
+		}
+	case ref >= memoryPageRef:
+		page := ref - memoryPageRef
+		for i, value := range s.page(page) {
+			vars = append(vars, variable{Name: fmt.Sprintf("[%d]", page*pageWords+i), Value: fmt.Sprint(value), Type: "uint32"})
+		}
+	}
+	return map[string]any{"variables": vars}, nil
+}
+
+func (s *Server) page(page int) []uint32 {
+	start := page * pageWords
+	if start < 0 || start >= len(s.vm.Memory) {
+		return nil
+	}
+	return s.vm.Memory[start:min(start+pageWords, len(s.vm.Memory))]
+}
+
+func isZero(words []uint32) bool {
+	for _, w := range words {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func formatWords(words []uint32) string {
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = fmt.Sprint(w)
+	}
+	return strings.Join(parts, " ")
+}
+
+// evaluate вычисляет выражение на языке условий отладчика (R9 + 1, mem[804], метка)
+func (s *Server) evaluate(req *request) (any, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return nil, err
+	}
+	if s.vm == nil {
+		return nil, fmt.Errorf("программа не загружена")
+	}
+
+	expr, err := debugger.ParseExpr(args.Expression, func(name string) (uint32, bool) {
+		value, ok := s.symbols[name]
+		return value, ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	value, err := expr.Eval(s.vm)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"result": fmt.Sprint(value), "variablesReference": 0}, nil
+}
+
+func (s *Server) cont(req *request) (any, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.pending = func() { s.run(0) }
+	return map[string]any{"allThreadsContinued": true}, nil
+}
+
+// next выполняет одну команду: каждая команда занимает отдельную строку,
+// поэтому шаг по строке и шаг по команде совпадают
+func (s *Server) next(req *request) (any, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.pending = func() { s.run(1) }
+	return nil, nil
+}
+
+// stepOut не поддерживается: в системе команд УВМ нет переходов и подпрограмм
+func (s *Server) stepOut(req *request) (any, error) {
+	return nil, fmt.Errorf("выход из подпрограммы не поддерживается: в системе команд УВМ нет подпрограмм")
+}
+
+// pause вне выполнения сразу сообщает об остановке; во время выполнения
+// запрос обрабатывает poll
+func (s *Server) pause(req *request) (any, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.pending = func() { s.stopped("pause", "") }
+	return nil, nil
+}
+
+func (s *Server) stepBack(req *request) (any, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.pending = func() { s.runBack(1) }
+	return nil, nil
+}
+
+func (s *Server) reverseContinue(req *request) (any, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.pending = func() { s.runBack(0) }
+	return nil, nil
+}
+
+func (s *Server) disconnect(req *request) (any, error) {
+	s.done = true
+	return nil, nil
+}
+
+func (s *Server) terminate(req *request) (any, error) {
+	s.pending = func() { s.sendEvent("terminated", nil) }
+	return nil, nil
+}
+
+func (s *Server) ready() error {
+	if s.vm == nil {
+		return fmt.Errorf("программа не загружена")
+	}
+	return nil
+}
+
+// run выполняет не более limit команд (0 - без ограничения) до точки останова,
+// ошибки, завершения программы или запроса pause
+func (s *Server) run(limit uint64) {
+	for i := uint64(1); ; i++ {
+		if s.vm.Halted {
+			s.finish()
+			return
+		}
+		if err := s.history.Step(); err != nil {
+			s.sendEvent("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+			s.stopped("exception", err.Error())
+			return
+		}
+
+		switch {
+		case s.vm.Halted:
+			s.finish()
+			return
+		case limit != 0 && i >= limit:
+			s.stopped("step", "")
+			return
+		case s.hasBreakpoint(s.vm.PC):
+			s.stopped("breakpoint", "")
+			return
+		}
+
+		if i%pollInterval == 0 && s.poll() {
+			return
+		}
+	}
+}
+
+// runBack выполняет назад не более limit команд (0 - до точки останова или начала истории)
+func (s *Server) runBack(limit uint64) {
+	for i := uint64(1); ; i++ {
+		if s.vm.Steps <= s.history.Oldest() {
+			s.stopped("step", "Начало записанной истории")
+			return
+		}
+		if _, err := s.history.Back(); err != nil {
+			s.stopped("exception", err.Error())
+			return
+		}
+
+		switch {
+		case limit != 0 && i >= limit:
+			s.stopped("step", "")
+			return
+		case s.hasBreakpoint(s.vm.PC):
+			s.stopped("breakpoint", "")
+			return
+		}
+
+		if i%pollInterval == 0 && s.poll() {
+			return
+		}
+	}
+}
+
+// poll обрабатывает запросы, пришедшие во время выполнения.
+// Возвращает true, если выполнение нужно остановить.
+func (s *Server) poll() bool {
+	for {
+		select {
+		case req, ok := <-s.requests:
+			if !ok {
+				s.done = true
+				return true
+			}
+			switch req.Command {
+			case "pause":
+				s.respond(req, nil)
+				s.stopped("pause", "")
+				return true
+			case "continue", "next", "stepIn", "stepBack", "reverseContinue":
+				s.fail(req, fmt.Errorf("программа выполняется"))
+			default:
+				s.dispatch(req)
+				if s.done {
+					return true
+				}
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func (s *Server) stopped(reason, text string) {
+	body := map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true}
+	if text != "" {
+		body["text"] = text
+		body["description"] = text
+	}
+	s.sendEvent("stopped", body)
+}
+
+// finish сообщает клиенту о завершении программы
+func (s *Server) finish() {
+	s.sendEvent("output", map[string]any{
+		"category": "console",
+		"output":   fmt.Sprintf("Программа выполнена: %d команд\n", s.vm.Steps),
+	})
+	s.sendEvent("exited", map[string]any{"exitCode": s.vm.ExitCode})
+	s.sendEvent("terminated", nil)
+}
+
+// programOutput передает вывод программы клиенту
+type programOutput struct {
+	s *Server
+}
+
+func (o programOutput) Write(p []byte) (int, error) {
+	o.s.sendEvent("output", map[string]any{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
@@ -0,0 +1,261 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testProgram = `LOAD R1 5
+LOAD R2 7
+SQRT R3 0
+`
+
+// message - ответ или событие сервера
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	RequestSeq int             `json:"request_seq"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client передает запросы серверу через io.Pipe и читает его сообщения
+type client struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out *bufio.Reader
+	seq int
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	server := NewServer(inR, outW)
+	done := make(chan error, 1)
+	go func() { done <- server.Run() }()
+	t.Cleanup(func() {
+		inW.Close()
+		outR.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+	return &client{t: t, in: inW, out: bufio.NewReader(outR)}
+}
+
+func (c *client) send(command string, args any) int {
+	c.t.Helper()
+	c.seq++
+	data, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.seq
+}
+
+func (c *client) read() message {
+	c.t.Helper()
+	length := 0
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("чтение сообщения: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.out, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// request отправляет запрос и возвращает успешный ответ на него, пропуская события
+func (c *client) request(command string, args any) message {
+	c.t.Helper()
+	msg := c.response(c.send(command, args))
+	if !msg.Success {
+		c.t.Fatalf("%s: %s", command, msg.Message)
+	}
+	return msg
+}
+
+// response ждет ответ на запрос seq, пропуская остальные сообщения
+func (c *client) response(seq int) message {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if msg.Type == "response" && msg.RequestSeq == seq {
+			return msg
+		}
+	}
+}
+
+// event ждет событие name, пропуская остальные сообщения
+func (c *client) event(name string) message {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if msg.Type != "event" {
+			continue
+		}
+		if msg.Event == name {
+			return msg
+		}
+		if msg.Event == "terminated" {
+			c.t.Fatalf("программа завершилась, ожидалось событие %s", name)
+		}
+	}
+}
+
+// launch запускает тестовую программу и устанавливает точку останова на строке line
+func launch(t *testing.T, c *client, line int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.asm")
+	if err := os.WriteFile(path, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.request("initialize", map[string]any{"adapterID": "uvm"})
+	c.request("launch", map[string]any{"program": path})
+	c.event("initialized")
+
+	var bps struct {
+		Breakpoints []struct {
+			Verified bool `json:"verified"`
+			Line     int  `json:"line"`
+		} `json:"breakpoints"`
+	}
+	resp := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": line}},
+	})
+	if err := json.Unmarshal(resp.Body, &bps); err != nil {
+		t.Fatal(err)
+	}
+	if len(bps.Breakpoints) != 1 || !bps.Breakpoints[0].Verified || bps.Breakpoints[0].Line != line {
+		t.Fatalf("setBreakpoints: %s", resp.Body)
+	}
+	return path
+}
+
+func TestBreakpointOnEntry(t *testing.T) {
+	c := startServer(t)
+	path := launch(t, c, 1)
+	c.request("configurationDone", nil)
+
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(c.event("stopped").Body, &stopped); err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Reason != "breakpoint" {
+		t.Fatalf("остановка по причине %q, ожидалась breakpoint", stopped.Reason)
+	}
+
+	var trace struct {
+		StackFrames []struct {
+			Line   int `json:"line"`
+			Source struct {
+				Path string `json:"path"`
+			} `json:"source"`
+		} `json:"stackFrames"`
+	}
+	if err := json.Unmarshal(c.request("stackTrace", map[string]any{"threadId": threadID}).Body, &trace); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 1 || trace.StackFrames[0].Source.Path != path {
+		t.Fatalf("stackTrace: %+v", trace)
+	}
+
+	c.request("continue", map[string]any{"threadId": threadID})
+	c.event("exited")
+	c.request("disconnect", nil)
+}
+
+func TestBreakpointAndVariables(t *testing.T) {
+	c := startServer(t)
+	launch(t, c, 3)
+	c.request("configurationDone", nil)
+	c.event("stopped")
+
+	var vars struct {
+		Variables []variable `json:"variables"`
+	}
+	resp := c.request("variables", map[string]any{"variablesReference": registersRef})
+	if err := json.Unmarshal(resp.Body, &vars); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"PC": "2", "шаг": "2", "R1": "5", "R2": "7"}
+	for _, v := range vars.Variables {
+		if value, ok := want[v.Name]; ok {
+			if v.Value != value {
+				t.Errorf("%s = %s, ожидалось %s", v.Name, v.Value, value)
+			}
+			delete(want, v.Name)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("нет переменных: %v", want)
+	}
+
+	c.request("stepBack", map[string]any{"threadId": threadID})
+	c.event("stopped")
+	resp = c.request("evaluate", map[string]any{"expression": "R2"})
+	if !strings.Contains(string(resp.Body), `"result":"0"`) {
+		t.Errorf("R2 после шага назад: %s", resp.Body)
+	}
+	c.request("disconnect", nil)
+}
+
+func TestPauseAndStepOut(t *testing.T) {
+	c := startServer(t)
+	launch(t, c, 1)
+	c.request("configurationDone", nil)
+	c.event("stopped")
+
+	c.request("pause", map[string]any{"threadId": threadID})
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(c.event("stopped").Body, &stopped); err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Reason != "pause" {
+		t.Errorf("остановка по причине %q, ожидалась pause", stopped.Reason)
+	}
+
+	resp := c.response(c.send("stepOut", map[string]any{"threadId": threadID}))
+	if resp.Success || !strings.Contains(resp.Message, "не поддерживается") {
+		t.Errorf("stepOut: success = %v, %q; ожидалась ошибка", resp.Success, resp.Message)
+	}
+	resp = c.request("evaluate", map[string]any{"expression": "PC"})
+	if !strings.Contains(string(resp.Body), `"result":"0"`) {
+		t.Errorf("PC после stepOut: %s", resp.Body)
+	}
+	c.request("disconnect", nil)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"uvm-assembler/dap"
+)
+
+// runDAP запускает сервер Debug Adapter Protocol на стандартных вводе и выводе
+func runDAP(args []string) {
+	fs := flag.NewFlagSet("dap", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Выводить сообщения протокола в stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: uvm-assembler dap [-v]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	server := dap.NewServer(os.Stdin, os.Stdout)
+	if *verbose {
+		server.Log = os.Stderr
+	}
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"uvm-assembler/assembler"
+	"uvm-assembler/debugger"
+	"uvm-assembler/emulator"
+)
+
+// loadedProgram - двоичная программа вместе с необязательными отладочными данными
+type loadedProgram struct {
+	image   *assembler.Image
+	debug   *assembler.DebugInfo
+	symbols *assembler.SymbolTable
+}
+
+// loadProgram читает двоичный файл, отладочную информацию <path>.dbg (если есть)
+// и файл символов (если задан)
+func loadProgram(path, symbolsFile string) *loadedProgram {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("❌ Ошибка чтения файла: %v\n", err)
+		os.Exit(1)
+	}
+
+	img, err := assembler.LoadImage(data)
+	if err != nil {
+		fmt.Printf("❌ Файл %s поврежден: %v\n", path, err)
+		os.Exit(1)
+	}
+	result := &loadedProgram{image: img}
+
+	if _, err := os.Stat(assembler.DebugInfoPath(path)); err == nil {
+		if result.debug, err = assembler.LoadDebugInfo(assembler.DebugInfoPath(path)); err != nil {
+			fmt.Printf("❌ Ошибка чтения отладочной информации: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if symbolsFile != "" {
+		if result.symbols, err = assembler.LoadSymbolTable(symbolsFile); err != nil {
+			fmt.Printf("❌ Ошибка чтения файла символов: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	return result
+}
+
+// newMachine создает машину из программы или, если задан snapshotFile, из снимка
+// состояния. Программа при загрузке снимка необязательна и нужна только
+// для отладочной информации.
+func newMachine(args []string, snapshotFile, symbolsFile string, model emulator.MemoryModel) (*emulator.VM, *loadedProgram) {
+	program := &loadedProgram{}
+	if len(args) == 1 {
+		program = loadProgram(args[0], symbolsFile)
+	}
+
+	var vm *emulator.VM
+	var err error
+	if snapshotFile != "" {
+		vm, err = emulator.LoadSnapshot(snapshotFile)
+		if err != nil {
+			fmt.Printf("❌ Ошибка чтения снимка: %v\n", err)
+			os.Exit(1)
+		}
+		if program.image != nil && !bytes.Equal(program.image.Code(), vm.Code) {
+			fmt.Printf("⚠️  Код программы %s отличается от кода в снимке: используется снимок\n", args[0])
+		}
+		fmt.Printf("📦 Состояние восстановлено из %s: шаг %d, PC = %d\n", snapshotFile, vm.Steps, vm.PC)
+	} else {
+		vm, err = emulator.NewWithModel(program.image, model)
+		if err != nil {
+			fmt.Printf("❌ Ошибка загрузки программы: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	vm.Debug = program.debug
+	return vm, program
+}
+
+// memoryFlags добавляет флаги модели памяти. Возвращаемая функция вызывается после
+// разбора флагов: она читает файл -memory-config и применяет поверх него явно
+// заданные флаги. При загрузке снимка модель берется из снимка.
+func memoryFlags(fs *flag.FlagSet) func() emulator.MemoryModel {
+	def := emulator.DefaultMemoryModel()
+	size := fs.Int("memory", def.Size, "Размер памяти данных в ячейках (словах или байтах)")
+	wordBits := fs.Uint("word-bits", def.WordBits, "Ширина слова памяти: 8, 16 или 32 бита")
+	addressing := fs.String("addressing", string(def.Addressing), "Адресация памяти: word (по словам) или byte (по байтам)")
+	bounds := fs.String("out-of-bounds", string(def.OutOfBounds), "Обращение за пределы памяти: trap (ошибка) или wrap (адрес по модулю размера)")
+	config := fs.String("memory-config", "", "Файл JSON с моделью памяти (флаги имеют приоритет)")
+
+	return func() emulator.MemoryModel {
+		model := def
+		if *config != "" {
+			var err error
+			if model, err = emulator.LoadMemoryModel(*config); err != nil {
+				fmt.Printf("❌ Ошибка чтения модели памяти: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "memory":
+				model.Size = *size
+			case "word-bits":
+				model.WordBits = *wordBits
+			case "addressing":
+				model.Addressing = emulator.Addressing(*addressing)
+			case "out-of-bounds":
+				model.OutOfBounds = emulator.BoundsPolicy(*bounds)
+			}
+		})
+		if err := model.Validate(); err != nil {
+			fmt.Printf("❌ Неверная модель памяти: %v\n", err)
+			os.Exit(1)
+		}
+		return model
+	}
+}
+
+// sqrtFlags добавляет флаги семантики SQRT. Возвращаемая функция вызывается после
+// разбора флагов и возвращает режим и признак знакового аргумента.
+func sqrtFlags(fs *flag.FlagSet) func() (emulator.SqrtMode, bool) {
+	mode := fs.String("sqrt-mode", string(emulator.SqrtFloor), "Семантика SQRT: "+strings.Join(emulator.SqrtModes, ", "))
+	signed := fs.Bool("sqrt-signed", false, "Считать аргумент SQRT знаковым: корень из отрицательного числа - ошибка")
+
+	return func() (emulator.SqrtMode, bool) {
+		m, err := emulator.ParseSqrtMode(*mode)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return m, *signed
+	}
+}
+
+// deviceFlags добавляет флаги стандартных устройств. Возвращаемая функция вызывается
+// после создания машины и подключает устройства. Порт ввода читает файл -console-input,
+// а если он не задан - in; при in == nil ввод сразу заканчивается.
+func deviceFlags(fs *flag.FlagSet) func(vm *emulator.VM, in io.Reader, out io.Writer) *emulator.ConsoleOut {
+	enabled := fs.Bool("devices", true, "Подключить стандартные устройства: вывод, ввод и останов")
+	base := fs.Uint("device-base", emulator.DefaultDeviceBase, "Адрес первого регистра стандартных устройств")
+	inputFile := fs.String("console-input", "", "Файл, который читает порт ввода")
+
+	return func(vm *emulator.VM, in io.Reader, out io.Writer) *emulator.ConsoleOut {
+		if !*enabled {
+			return nil
+		}
+		if *inputFile != "" {
+			data, err := os.ReadFile(*inputFile)
+			if err != nil {
+				fmt.Printf("❌ Ошибка чтения ввода: %v\n", err)
+				os.Exit(1)
+			}
+			in = bytes.NewReader(data)
+		}
+		if in == nil {
+			in = bytes.NewReader(nil)
+		}
+
+		console, err := vm.AttachStandardDevices(uint32(*base), in, out)
+		if err != nil {
+			fmt.Printf("❌ Ошибка подключения устройств: %v\n", err)
+			os.Exit(1)
+		}
+		return console
+	}
+}
+
+// runDebug запускает интерактивный пошаговый отладчик
+func runDebug(args []string) {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	symbolsFile := fs.String("symbols", "", "Файл символов (JSON), записанный link -symbols")
+	memoryModel := memoryFlags(fs)
+	history := fs.Int("history", emulator.DefaultHistoryLimit, "Сколько последних команд хранить для выполнения назад (0 - не записывать историю)")
+	loadSnapshot := fs.String("load-snapshot", "", "Начать отладку с состояния из снимка")
+	sqrtOptions := sqrtFlags(fs)
+	devices := deviceFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler debug [-symbols program.sym.json] program.bin")
+		fmt.Println("               uvm-assembler debug -load-snapshot state.snap [program.bin]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 || (fs.NArg() == 0 && *loadSnapshot == "") {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	vm, program := newMachine(fs.Args(), *loadSnapshot, *symbolsFile, memoryModel())
+	vm.SqrtMode, vm.SqrtSigned = sqrtOptions()
+	// Стандартный ввод занят командами отладчика
+	devices(vm, nil, os.Stdout)
+
+	d := debugger.New(vm, program.debug, program.symbols)
+	d.SetHistoryLimit(*history)
+	if err := d.Run(os.Stdin, os.Stdout); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+}
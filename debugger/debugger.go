@@ -0,0 +1,870 @@
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"uvm-assembler/assembler"
+	"uvm-assembler/emulator"
+)
+
+// Breakpoint - точка останова на адресе команды. Точка с условием срабатывает,
+// только если условие истинно; точка без адреса (Anywhere) проверяет условие
+// после каждой команды и срабатывает, когда оно становится истинным.
+type Breakpoint struct {
+	ID       int
+	Addr     uint32
+	Anywhere bool
+	Cond     *Expr
+	Where    string // как точка была задана пользователем
+	Enabled  bool
+
+	wasTrue bool // значение условия после предыдущей команды (для Anywhere)
+}
+
+// Watchpoint - точка наблюдения за диапазоном адресов памяти [From, To]
+type Watchpoint struct {
+	ID    int
+	From  uint32
+	To    uint32
+	Read  bool
+	Write bool
+	Where string
+}
+
+// matches сообщает, затрагивает ли обращение к памяти точку наблюдения
+func (wp *Watchpoint) matches(a emulator.Access) bool {
+	if a.Addr < wp.From || a.Addr > wp.To {
+		return false
+	}
+	return (a.Kind == emulator.AccessRead && wp.Read) || (a.Kind == emulator.AccessWrite && wp.Write)
+}
+
+// Debugger - пошаговый отладчик программы УВМ с интерактивной командной строкой
+type Debugger struct {
+	vm          *emulator.VM
+	history     *emulator.Recorder // nil, если запись истории выключена
+	debug       *assembler.DebugInfo
+	symbols     *assembler.SymbolTable
+	breakpoints []*Breakpoint
+	watchpoints []*Watchpoint
+	nextID      int
+	lastCommand string
+	out         io.Writer
+}
+
+// errQuit возвращает команда quit, чтобы Execute завершил отладку
+var errQuit = errors.New("выход из отладчика")
+
+// command - команда отладчика
+type command struct {
+	names []string
+	usage string // формы вызова, по одной на строку
+	help  string // описание каждой формы, по одной на строку
+	run   func(d *Debugger, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{[]string{"step", "s"}, "step [n]", "выполнить n команд (по умолчанию одну)", (*Debugger).cmdStep},
+		{[]string{"continue", "c"}, "continue", "выполнять до точки останова или завершения", (*Debugger).cmdContinue},
+		{[]string{"break", "b"}, "break <адрес|символ|[файл]:строка> [if <условие>]\nbreak if <условие>", "поставить точку останова\nостановиться, когда условие станет истинным", (*Debugger).cmdBreak},
+		{[]string{"watch", "wa"}, "watch [read|write|access] <адрес|символ|от..до>", "остановиться при обращении к памяти", (*Debugger).cmdWatch},
+		{[]string{"delete", "d"}, "delete <номер>", "удалить точку останова или наблюдения", (*Debugger).cmdDelete},
+		{[]string{"reverse-step", "rs"}, "reverse-step [n]", "отменить n последних команд", (*Debugger).cmdReverseStep},
+		{[]string{"reverse-continue", "rc"}, "reverse-continue", "выполнять назад до точки останова или наблюдения", (*Debugger).cmdReverseContinue},
+		{[]string{"goto"}, "goto <шаг>", "перейти к состоянию перед шагом с этим номером", (*Debugger).cmdGoto},
+		{[]string{"history"}, "history", "показать доступную историю выполнения", (*Debugger).cmdHistory},
+		{[]string{"snapshot"}, "snapshot <файл>", "сохранить снимок состояния машины", (*Debugger).cmdSnapshot},
+		{[]string{"restore"}, "restore <файл>", "восстановить состояние машины из снимка", (*Debugger).cmdRestore},
+		{[]string{"breakpoints", "bl"}, "breakpoints", "список точек останова и наблюдения", (*Debugger).cmdBreakpoints},
+		{[]string{"print", "p"}, "print <выражение>", "вычислить выражение (R9 > 100 && mem[804] == 0)", (*Debugger).cmdPrint},
+		{[]string{"regs", "r"}, "regs [Rn ...]", "показать регистры", (*Debugger).cmdRegs},
+		{[]string{"set"}, "set Rn <значение>", "изменить регистр", (*Debugger).cmdSet},
+		{[]string{"mem", "x"}, "mem <адрес|символ> [количество]", "показать память данных", (*Debugger).cmdMem},
+		{[]string{"setmem"}, "setmem <адрес|символ> <значение>", "изменить слово памяти", (*Debugger).cmdSetMem},
+		{[]string{"disas", "l"}, "disas [адрес] [количество]", "дизассемблировать команды (по умолчанию вокруг PC)", (*Debugger).cmdDisas},
+		{[]string{"where", "w"}, "where", "показать текущую команду", (*Debugger).cmdWhere},
+		{[]string{"help", "h", "?"}, "help", "список команд", (*Debugger).cmdHelp},
+		{[]string{"quit", "q"}, "quit", "выйти из отладчика", (*Debugger).cmdQuit},
+	}
+}
+
+// New создает отладчик. Отладочная информация и таблица символов необязательны.
+// Запись истории для выполнения назад включена с параметрами по умолчанию.
+func New(vm *emulator.VM, debug *assembler.DebugInfo, symbols *assembler.SymbolTable) *Debugger {
+	return &Debugger{
+		vm:      vm,
+		history: emulator.NewRecorder(vm),
+		debug:   debug,
+		symbols: symbols,
+		nextID:  1,
+		out:     io.Discard,
+	}
+}
+
+// SetHistoryLimit задает, сколько последних команд можно отменить без повторного
+// выполнения; 0 выключает запись истории
+func (d *Debugger) SetHistoryLimit(limit int) {
+	if limit <= 0 {
+		d.history = nil
+		return
+	}
+	if d.history == nil {
+		d.history = emulator.NewRecorder(d.vm)
+	}
+	d.history.Limit = limit
+}
+
+// Run читает команды из in и выводит результаты в out до команды quit или конца ввода
+func (d *Debugger) Run(in io.Reader, out io.Writer) error {
+	d.out = out
+	scanner := bufio.NewScanner(in)
+
+	fmt.Fprintf(out, "Отладчик УВМ: %d команд, help - список команд\n", d.vm.Len())
+	d.printLocation()
+
+	for {
+		fmt.Fprint(out, "(uvm) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		if !d.Execute(scanner.Text()) {
+			return nil
+		}
+	}
+}
+
+// Execute выполняет одну строку отладчика. Пустая строка повторяет предыдущую команду.
+// Возвращает false, если пользователь завершил отладку.
+func (d *Debugger) Execute(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		line = d.lastCommand
+	}
+	if line == "" {
+		return true
+	}
+	d.lastCommand = line
+
+	parts := strings.Fields(line)
+	for _, c := range commands {
+		for _, name := range c.names {
+			if name != parts[0] {
+				continue
+			}
+			if err := c.run(d, parts[1:]); errors.Is(err, errQuit) {
+				return false
+			} else if isHalted(err) {
+				fmt.Fprintf(d.out, "🏁 %v\n", err)
+			} else if err != nil {
+				fmt.Fprintf(d.out, "❌ %v\n", err)
+			}
+			return true
+		}
+	}
+
+	fmt.Fprintf(d.out, "❌ неизвестная команда: %s (help - список команд)\n", parts[0])
+	return true
+}
+
+func (d *Debugger) cmdStep(args []string) error {
+	count := uint64(1)
+	if len(args) > 0 {
+		n, err := strconv.ParseUint(args[0], 0, 64)
+		if err != nil {
+			return fmt.Errorf("неверное количество шагов: %s", args[0])
+		}
+		count = n
+	}
+
+	if err := d.run(count); err != nil {
+		return err
+	}
+	d.printLocation()
+	return nil
+}
+
+func (d *Debugger) cmdContinue(args []string) error {
+	if err := d.run(0); err != nil {
+		return err
+	}
+	d.printLocation()
+	return nil
+}
+
+// run выполняет не более limit команд (0 - без ограничения) до точки останова,
+// точки наблюдения, ошибки или завершения программы
+func (d *Debugger) run(limit uint64) error {
+	for i := uint64(0); limit == 0 || i < limit; i++ {
+		if d.vm.Halted {
+			return emulator.ErrHalted
+		}
+
+		err := d.step()
+		stop, condErr := d.checkStop(d.vm.Accesses)
+		if err != nil {
+			return err
+		}
+		if condErr != nil {
+			return condErr
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// step выполняет одну команду и сообщает о завершении программы
+func (d *Debugger) step() error {
+	exec := d.vm.Step
+	if d.history != nil {
+		exec = d.history.Step
+	}
+	if err := exec(); err != nil {
+		return d.describeError(err)
+	}
+	if d.vm.Halted {
+		return fmt.Errorf("%w: выполнено %d команд", emulator.ErrHalted, d.vm.Steps)
+	}
+	return nil
+}
+
+// checkStop проверяет точки наблюдения по обращениям выполненной (или отмененной)
+// команды и точки останова на текущем значении PC
+func (d *Debugger) checkStop(accesses []emulator.Access) (bool, error) {
+	stop := false
+	for _, wp := range d.watchpoints {
+		for _, a := range accesses {
+			if !wp.matches(a) {
+				continue
+			}
+			stop = true
+			if a.Kind == emulator.AccessWrite {
+				fmt.Fprintf(d.out, "Точка наблюдения %d (%s): запись [%d] %d -> %d\n", wp.ID, wp.Where, a.Addr, a.Old, a.Value)
+			} else {
+				fmt.Fprintf(d.out, "Точка наблюдения %d (%s): чтение [%d] = %d\n", wp.ID, wp.Where, a.Addr, a.Value)
+			}
+		}
+	}
+
+	for _, bp := range d.breakpoints {
+		if !bp.Enabled || d.vm.Halted || (!bp.Anywhere && bp.Addr != d.vm.PC) {
+			continue
+		}
+		if bp.Cond != nil {
+			ok, err := bp.Cond.True(d.vm)
+			if err != nil {
+				return true, fmt.Errorf("условие точки останова %d (%s): %v", bp.ID, bp.Cond, err)
+			}
+			if bp.Anywhere {
+				ok, bp.wasTrue = ok && !bp.wasTrue, ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		stop = true
+		fmt.Fprintf(d.out, "Точка останова %d (%s)\n", bp.ID, bp.Where)
+	}
+	return stop, nil
+}
+
+// describeError дополняет ошибку выполнения местом в исходном тексте
+func (d *Debugger) describeError(err error) error {
+	var fault *emulator.Fault
+	if errors.As(err, &fault) && fault.Source != nil {
+		return err
+	}
+	if entry, ok := d.debug.Lookup(d.vm.PC); ok {
+		return fmt.Errorf("%v (%s)", err, entry.SourceLocation)
+	}
+	return err
+}
+
+func (d *Debugger) cmdReverseStep(args []string) error {
+	count := uint64(1)
+	if len(args) > 0 {
+		n, err := strconv.ParseUint(args[0], 0, 64)
+		if err != nil {
+			return fmt.Errorf("неверное количество шагов: %s", args[0])
+		}
+		count = n
+	}
+
+	if err := d.runBack(count); err != nil {
+		return err
+	}
+	d.printLocation()
+	return nil
+}
+
+func (d *Debugger) cmdReverseContinue(args []string) error {
+	if err := d.runBack(0); err != nil {
+		return err
+	}
+	d.printLocation()
+	return nil
+}
+
+// runBack отменяет не более limit команд (0 - без ограничения) до точки останова,
+// точки наблюдения или начала истории
+func (d *Debugger) runBack(limit uint64) error {
+	if d.history == nil {
+		return fmt.Errorf("запись истории выключена")
+	}
+
+	for i := uint64(0); limit == 0 || i < limit; i++ {
+		delta, err := d.history.Back()
+		if err != nil {
+			if i > 0 {
+				fmt.Fprintf(d.out, "%v\n", err)
+				return nil
+			}
+			return err
+		}
+
+		stop, condErr := d.checkStop(delta.Accesses)
+		if condErr != nil {
+			return condErr
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (d *Debugger) cmdGoto(args []string) error {
+	if d.history == nil {
+		return fmt.Errorf("запись истории выключена")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("использование: goto <шаг>")
+	}
+	step, err := strconv.ParseUint(args[0], 0, 64)
+	if err != nil {
+		return fmt.Errorf("неверный номер шага: %s", args[0])
+	}
+
+	if err := d.history.Goto(step); err != nil {
+		return d.describeError(err)
+	}
+	d.printLocation()
+	return nil
+}
+
+func (d *Debugger) cmdHistory(args []string) error {
+	if d.history == nil {
+		fmt.Fprintln(d.out, "Запись истории выключена")
+		return nil
+	}
+	fmt.Fprintf(d.out, "История: шаги %d..%d, текущий шаг %d\n", d.history.Oldest(), d.vm.Steps, d.vm.Steps)
+	return nil
+}
+
+func (d *Debugger) cmdSnapshot(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("использование: snapshot <файл>")
+	}
+	if err := d.vm.SaveSnapshot(args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(d.out, "Снимок состояния на шаге %d записан в %s\n", d.vm.Steps, args[0])
+	return nil
+}
+
+func (d *Debugger) cmdRestore(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("использование: restore <файл>")
+	}
+	restored, err := emulator.LoadSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Машина заменяется на месте: история и трассировщик ссылаются на нее.
+	// Настройки запуска не входят в снимок и сохраняются; устройства остаются
+	// прежними и получают состояния из снимка.
+	restored.Debug, restored.Tracer, restored.Profile = d.vm.Debug, d.vm.Tracer, d.vm.Profile
+	restored.SqrtMode, restored.SqrtSigned = d.vm.SqrtMode, d.vm.SqrtSigned
+	for _, m := range d.vm.Devices {
+		if err := restored.Attach(m.Base, m.Device); err != nil {
+			return err
+		}
+	}
+	*d.vm = *restored
+	fmt.Fprintf(d.out, "Состояние восстановлено из %s\n", args[0])
+	d.stateChanged()
+	d.printLocation()
+	return nil
+}
+
+// stateChanged сообщает истории, что состояние машины изменено извне
+func (d *Debugger) stateChanged() {
+	if d.history != nil {
+		d.history.Reset()
+		fmt.Fprintf(d.out, "История выполнения начинается заново с шага %d\n", d.vm.Steps)
+	}
+}
+
+func (d *Debugger) cmdBreak(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("использование: break <адрес|символ|[файл]:строка> [if <условие>] или break if <условие>")
+	}
+
+	var cond *Expr
+	location := args
+	for i, arg := range args {
+		if arg != "if" {
+			continue
+		}
+		var err error
+		if cond, err = ParseExpr(strings.Join(args[i+1:], " "), d.resolveSymbol); err != nil {
+			return err
+		}
+		location = args[:i]
+		break
+	}
+
+	if len(location) == 0 {
+		if cond == nil {
+			return fmt.Errorf("использование: break if <условие>")
+		}
+		bp := &Breakpoint{ID: d.nextID, Anywhere: true, Cond: cond, Where: "if " + cond.String(), Enabled: true}
+		d.nextID++
+		d.breakpoints = append(d.breakpoints, bp)
+		fmt.Fprintf(d.out, "Точка останова %d: когда %s\n", bp.ID, cond)
+		return nil
+	}
+	if len(location) != 1 {
+		return fmt.Errorf("использование: break <адрес|символ|[файл]:строка> [if <условие>]")
+	}
+
+	addrs, err := d.resolveCode(location[0])
+	if err != nil {
+		return err
+	}
+
+	where := strings.Join(args, " ")
+	for _, addr := range addrs {
+		bp := &Breakpoint{ID: d.nextID, Addr: addr, Cond: cond, Where: where, Enabled: true}
+		d.nextID++
+		d.breakpoints = append(d.breakpoints, bp)
+		fmt.Fprintf(d.out, "Точка останова %d: адрес %d%s\n", bp.ID, addr, d.annotate(addr))
+	}
+	return nil
+}
+
+func (d *Debugger) cmdWatch(args []string) error {
+	wp := &Watchpoint{Read: true, Write: true}
+	if len(args) == 2 {
+		switch args[0] {
+		case "read":
+			wp.Write = false
+		case "write":
+			wp.Read = false
+		case "access":
+		default:
+			return fmt.Errorf("вид обращения должен быть read, write или access: %s", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("использование: watch [read|write|access] <адрес|символ|от..до>")
+	}
+
+	var err error
+	if from, to, ok := strings.Cut(args[0], ".."); ok {
+		if wp.From, err = d.resolveData(from); err != nil {
+			return err
+		}
+		if wp.To, err = d.resolveData(to); err != nil {
+			return err
+		}
+	} else {
+		if wp.From, err = d.resolveData(args[0]); err != nil {
+			return err
+		}
+		wp.To = wp.From
+		if d.symbols != nil {
+			if sym, ok := d.symbols.Find(args[0]); ok && sym.Size > 1 {
+				wp.To = wp.From + sym.Size - 1
+			}
+		}
+	}
+	if wp.To < wp.From {
+		return fmt.Errorf("пустой диапазон адресов: %s", args[0])
+	}
+
+	kind := "access"
+	if !wp.Read {
+		kind = "write"
+	} else if !wp.Write {
+		kind = "read"
+	}
+	wp.ID = d.nextID
+	wp.Where = kind + " " + args[0]
+	d.nextID++
+	d.watchpoints = append(d.watchpoints, wp)
+	fmt.Fprintf(d.out, "Точка наблюдения %d: %s [%d..%d]\n", wp.ID, kind, wp.From, wp.To)
+	return nil
+}
+
+func (d *Debugger) cmdPrint(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("использование: print <выражение>")
+	}
+	expr, err := ParseExpr(strings.Join(args, " "), d.resolveSymbol)
+	if err != nil {
+		return err
+	}
+	value, err := expr.Eval(d.vm)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(d.out, "%s = %d\n", expr, value)
+	return nil
+}
+
+func (d *Debugger) cmdDelete(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("использование: delete <номер>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("неверный номер точки останова: %s", args[0])
+	}
+
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			fmt.Fprintf(d.out, "Точка останова %d удалена\n", id)
+			return nil
+		}
+	}
+	for i, wp := range d.watchpoints {
+		if wp.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			fmt.Fprintf(d.out, "Точка наблюдения %d удалена\n", id)
+			return nil
+		}
+	}
+	return fmt.Errorf("точка останова %d не найдена", id)
+}
+
+func (d *Debugger) cmdBreakpoints(args []string) error {
+	if len(d.breakpoints) == 0 && len(d.watchpoints) == 0 {
+		fmt.Fprintln(d.out, "Точек останова нет")
+		return nil
+	}
+	for _, bp := range d.breakpoints {
+		if bp.Anywhere {
+			fmt.Fprintf(d.out, "  %d: когда %s\n", bp.ID, bp.Cond)
+			continue
+		}
+		fmt.Fprintf(d.out, "  %d: адрес %d%s (%s)\n", bp.ID, bp.Addr, d.annotate(bp.Addr), bp.Where)
+	}
+	for _, wp := range d.watchpoints {
+		fmt.Fprintf(d.out, "  %d: наблюдение [%d..%d] (%s)\n", wp.ID, wp.From, wp.To, wp.Where)
+	}
+	return nil
+}
+
+func (d *Debugger) cmdRegs(args []string) error {
+	if len(args) > 0 {
+		for _, arg := range args {
+			reg, err := parseRegister(arg)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(d.out, "R%d = %d (0x%X)\n", reg, d.vm.Regs[reg], d.vm.Regs[reg])
+		}
+		return nil
+	}
+
+	fmt.Fprintf(d.out, "PC = %d, выполнено команд: %d\n", d.vm.PC, d.vm.Steps)
+	for row := 0; row < emulator.RegisterCount/4; row++ {
+		for col := 0; col < 4; col++ {
+			reg := row + col*emulator.RegisterCount/4
+			fmt.Fprintf(d.out, "R%-2d = %-12d", reg, d.vm.Regs[reg])
+		}
+		fmt.Fprintln(d.out)
+	}
+	return nil
+}
+
+func (d *Debugger) cmdSet(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("использование: set Rn <значение>")
+	}
+	reg, err := parseRegister(args[0])
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(args[1])
+	if err != nil {
+		return err
+	}
+
+	d.vm.Regs[reg] = value
+	fmt.Fprintf(d.out, "R%d = %d\n", reg, value)
+	d.stateChanged()
+	return nil
+}
+
+func (d *Debugger) cmdMem(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("использование: mem <адрес|символ> [количество]")
+	}
+	addr, err := d.resolveData(args[0])
+	if err != nil {
+		return err
+	}
+	count := uint32(1)
+	if len(args) == 2 {
+		if count, err = parseValue(args[1]); err != nil {
+			return err
+		}
+	}
+
+	// При адресации по байтам показываются слова подряд, а не с шагом в один байт
+	step := d.vm.Model.WordCells()
+	for i := uint32(0); i < count; i++ {
+		at := addr + i*step
+		value, err := d.vm.ReadMemory(at)
+		if err != nil {
+			return err
+		}
+		name := d.symbols.Describe(assembler.SectionData, at)
+		if name != "" {
+			name = " <" + name + ">"
+		}
+		fmt.Fprintf(d.out, "  [%d]%s = %d (0x%X)\n", at, name, value, value)
+	}
+	return nil
+}
+
+func (d *Debugger) cmdSetMem(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("использование: setmem <адрес|символ> <значение>")
+	}
+	addr, err := d.resolveData(args[0])
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := d.vm.WriteMemory(addr, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(d.out, "[%d] = %d\n", addr, value)
+	d.stateChanged()
+	return nil
+}
+
+func (d *Debugger) cmdDisas(args []string) error {
+	start, count := d.vm.PC, uint32(8)
+	if start >= 3 {
+		start -= 3
+	} else {
+		start = 0
+	}
+
+	if len(args) > 0 {
+		addrs, err := d.resolveCode(args[0])
+		if err != nil {
+			return err
+		}
+		start = addrs[0]
+	}
+	if len(args) > 1 {
+		n, err := parseValue(args[1])
+		if err != nil {
+			return err
+		}
+		count = n
+	}
+
+	for addr := start; addr < start+count && addr < d.vm.Len(); addr++ {
+		d.printInstruction(addr)
+	}
+	return nil
+}
+
+func (d *Debugger) cmdWhere(args []string) error {
+	d.printLocation()
+	return nil
+}
+
+func (d *Debugger) cmdHelp(args []string) error {
+	for _, c := range commands {
+		// у команды может быть несколько форм: строки usage и help идут парами
+		usages, helps := strings.Split(c.usage, "\n"), strings.Split(c.help, "\n")
+		for i, usage := range usages {
+			fmt.Fprintf(d.out, "  %-45s %s\n", usage, helps[min(i, len(helps)-1)])
+		}
+	}
+	fmt.Fprintln(d.out, "  Пустая строка повторяет предыдущую команду.")
+	return nil
+}
+
+func (d *Debugger) cmdQuit(args []string) error {
+	return errQuit
+}
+
+// printLocation выводит текущую команду или сообщение о завершении программы
+func (d *Debugger) printLocation() {
+	if d.vm.Halted {
+		fmt.Fprintf(d.out, "Программа завершена (PC = %d, выполнено команд: %d)\n", d.vm.PC, d.vm.Steps)
+		return
+	}
+	d.printInstruction(d.vm.PC)
+}
+
+// printInstruction выводит дизассемблированную команду с меткой и строкой исходного текста
+func (d *Debugger) printInstruction(addr uint32) {
+	marker := "  "
+	if addr == d.vm.PC && !d.vm.Halted {
+		marker = "=>"
+	}
+	if d.breakpointAt(addr) != nil {
+		marker = marker[:1] + "*"
+	}
+
+	text := "???"
+	if cmd, err := d.vm.Fetch(addr); err == nil {
+		text = assembler.Disassemble(cmd)
+	} else {
+		text = err.Error()
+	}
+
+	source := ""
+	if entry, ok := d.debug.Lookup(addr); ok {
+		source = "  ; " + entry.SourceLocation.String()
+	}
+	fmt.Fprintf(d.out, "%s %4d%s: %-24s%s\n", marker, addr, d.annotate(addr), text, source)
+}
+
+// annotate возвращает имя адреса команды в виде " <символ+смещение>"
+func (d *Debugger) annotate(addr uint32) string {
+	if name := d.symbols.Describe(assembler.SectionCode, addr); name != "" {
+		return " <" + name + ">"
+	}
+	return ""
+}
+
+// breakpointAt возвращает включенную точку останова на адресе
+func (d *Debugger) breakpointAt(addr uint32) *Breakpoint {
+	for _, bp := range d.breakpoints {
+		if bp.Enabled && !bp.Anywhere && bp.Addr == addr {
+			return bp
+		}
+	}
+	return nil
+}
+
+// resolveCode переводит адрес, символ или [файл]:строка в адреса команд
+func (d *Debugger) resolveCode(s string) ([]uint32, error) {
+	if idx := strings.LastIndex(s, ":"); idx >= 0 {
+		line, err := strconv.Atoi(s[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("неверный номер строки: %s", s)
+		}
+		return d.lineAddresses(s[:idx], line)
+	}
+
+	if value, err := parseValue(s); err == nil {
+		if value >= d.vm.Len() {
+			return nil, fmt.Errorf("адрес %d за пределами программы (%d команд)", value, d.vm.Len())
+		}
+		return []uint32{value}, nil
+	}
+
+	if d.symbols != nil {
+		if sym, ok := d.symbols.Find(s); ok && sym.Section == assembler.SectionCode.String() {
+			return []uint32{sym.Address}, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный адрес или символ кода: %s", s)
+}
+
+// lineAddresses возвращает адреса команд строки исходного текста
+func (d *Debugger) lineAddresses(file string, line int) ([]uint32, error) {
+	if d.debug == nil {
+		return nil, fmt.Errorf("нет отладочной информации: соберите программу с флагом -g")
+	}
+	if file == "" {
+		files := d.debug.Files()
+		if len(files) != 1 {
+			return nil, fmt.Errorf("в программе %d исходных файлов: укажите файл перед :%d", len(files), line)
+		}
+		file = files[0]
+	}
+	addrs := d.debug.Addresses(file, line)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("для строки %s:%d нет команд", file, line)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs[:1], nil
+}
+
+// resolveData переводит адрес или символ данных в адрес памяти
+func (d *Debugger) resolveData(s string) (uint32, error) {
+	if value, err := parseValue(s); err == nil {
+		return value, nil
+	}
+	if d.symbols != nil {
+		if sym, ok := d.symbols.Find(s); ok && sym.Section != assembler.SectionCode.String() {
+			return sym.Address, nil
+		}
+	}
+	return 0, fmt.Errorf("неизвестный адрес или символ данных: %s", s)
+}
+
+// resolveSymbol возвращает адрес символа для выражений
+func (d *Debugger) resolveSymbol(name string) (uint32, bool) {
+	if d.symbols == nil {
+		return 0, false
+	}
+	sym, ok := d.symbols.Find(name)
+	return sym.Address, ok
+}
+
+// parseRegister разбирает имя регистра R0-R63
+func parseRegister(s string) (int, error) {
+	s = strings.ToUpper(s)
+	if !strings.HasPrefix(s, "R") {
+		return 0, fmt.Errorf("неверный формат регистра: %s, ожидается R0-R63", s)
+	}
+	reg, err := strconv.Atoi(s[1:])
+	if err != nil || reg < 0 || reg >= emulator.RegisterCount {
+		return 0, fmt.Errorf("номер регистра должен быть от 0 до 63: %s", s)
+	}
+	return reg, nil
+}
+
+// parseValue разбирает десятичное или шестнадцатеричное (0x) число
+func parseValue(s string) (uint32, error) {
+	value, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("неверный числовой формат: %s", s)
+	}
+	return uint32(value), nil
+}
+
+// isHalted сообщает, является ли ошибка сообщением о завершении программы
+func isHalted(err error) bool {
+	return errors.Is(err, emulator.ErrHalted)
+}
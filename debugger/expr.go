@@ -0,0 +1,352 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"uvm-assembler/emulator"
+)
+
+// Язык условий точек останова:
+//
+//	expr    := and ('||' and)*
+//	and     := cmp ('&&' cmp)*
+//	cmp     := sum (('==' | '!=' | '<' | '<=' | '>' | '>=') sum)?
+//	sum     := term (('+' | '-') term)*
+//	term    := unary (('*' | '/' | '%') unary)*
+//	unary   := ('!' | '-') unary | primary
+//	primary := число | Rn | PC | mem '[' expr ']' | символ | '(' expr ')'
+//
+// Значения - целые числа со знаком; ложь - 0, истина - 1.
+
+// Expr - разобранное выражение
+type Expr struct {
+	source string
+	root   node
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval вычисляет выражение на текущем состоянии машины
+func (e *Expr) Eval(vm *emulator.VM) (int64, error) {
+	return e.root.eval(vm)
+}
+
+// True вычисляет выражение как условие
+func (e *Expr) True(vm *emulator.VM) (bool, error) {
+	value, err := e.Eval(vm)
+	return value != 0, err
+}
+
+type node interface {
+	eval(vm *emulator.VM) (int64, error)
+}
+
+type constNode int64
+
+func (n constNode) eval(*emulator.VM) (int64, error) {
+	return int64(n), nil
+}
+
+type regNode int
+
+func (n regNode) eval(vm *emulator.VM) (int64, error) {
+	return int64(vm.Regs[n]), nil
+}
+
+type pcNode struct{}
+
+func (pcNode) eval(vm *emulator.VM) (int64, error) {
+	return int64(vm.PC), nil
+}
+
+type memNode struct {
+	addr node
+}
+
+func (n memNode) eval(vm *emulator.VM) (int64, error) {
+	addr, err := n.addr.eval(vm)
+	if err != nil {
+		return 0, err
+	}
+	if addr < 0 || addr > int64(^uint32(0)) {
+		return 0, fmt.Errorf("неверный адрес памяти: %d", addr)
+	}
+	value, err := vm.ReadMemory(uint32(addr))
+	return int64(value), err
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n unaryNode) eval(vm *emulator.VM) (int64, error) {
+	v, err := n.operand.eval(vm)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "-" {
+		return -v, nil
+	}
+	return boolValue(v == 0), nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n binaryNode) eval(vm *emulator.VM) (int64, error) {
+	l, err := n.left.eval(vm)
+	if err != nil {
+		return 0, err
+	}
+
+	// && и || вычисляются по короткой схеме
+	switch {
+	case n.op == "&&" && l == 0:
+		return 0, nil
+	case n.op == "||" && l != 0:
+		return 1, nil
+	}
+
+	r, err := n.right.eval(vm)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return boolValue(r != 0), nil
+	case "==":
+		return boolValue(l == r), nil
+	case "!=":
+		return boolValue(l != r), nil
+	case "<":
+		return boolValue(l < r), nil
+	case "<=":
+		return boolValue(l <= r), nil
+	case ">":
+		return boolValue(l > r), nil
+	case ">=":
+		return boolValue(l >= r), nil
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return 0, fmt.Errorf("деление на ноль")
+		}
+		if n.op == "/" {
+			return l / r, nil
+		}
+		return l % r, nil
+	}
+	return 0, fmt.Errorf("неизвестная операция: %s", n.op)
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ParseExpr разбирает выражение. resolve переводит имена символов в адреса
+// и может быть nil, если символы не поддерживаются.
+func ParseExpr(source string, resolve func(name string) (uint32, bool)) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, resolve: resolve}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("лишний текст в выражении: %s", p.tokens[p.pos])
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// tokenize разбивает выражение на числа, имена и операторы
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			if i+1 < len(runes) {
+				pair := string(runes[i : i+2])
+				if pair == "&&" || pair == "||" || pair == "==" || pair == "!=" || pair == "<=" || pair == ">=" {
+					tokens = append(tokens, pair)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("<>+-*/%!()[]", r) {
+				return nil, fmt.Errorf("неожиданный символ в выражении: %q", r)
+			}
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens  []string
+	pos     int
+	resolve func(name string) (uint32, bool)
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) expect(t string) error {
+	if got := p.next(); got != t {
+		if got == "" {
+			got = "конец выражения"
+		}
+		return fmt.Errorf("ожидается %s, найдено %s", t, got)
+	}
+	return nil
+}
+
+// parseLevel разбирает левоассоциативную цепочку операций одного приоритета
+func (p *exprParser) parseLevel(ops []string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		found := false
+		for _, candidate := range ops {
+			if op == candidate {
+				found = true
+			}
+		}
+		if !found {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (node, error) {
+	return p.parseLevel([]string{"||"}, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (node, error) {
+	return p.parseLevel([]string{"&&"}, p.parseCmp)
+}
+
+func (p *exprParser) parseCmp() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseSum() (node, error) {
+	return p.parseLevel([]string{"+", "-"}, p.parseTerm)
+}
+
+func (p *exprParser) parseTerm() (node, error) {
+	return p.parseLevel([]string{"*", "/", "%"}, p.parseUnary)
+}
+
+func (p *exprParser) parseUnary() (node, error) {
+	if op := p.peek(); op == "!" || op == "-" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (node, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("неожиданный конец выражения")
+	case t == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case strings.EqualFold(t, "mem") && p.peek() == "[":
+		p.next()
+		addr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return memNode{addr: addr}, p.expect("]")
+	case strings.EqualFold(t, "pc"):
+		return pcNode{}, nil
+	case unicode.IsDigit(rune(t[0])):
+		value, err := strconv.ParseInt(t, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверное число: %s", t)
+		}
+		return constNode(value), nil
+	}
+
+	if reg, err := parseRegister(t); err == nil {
+		return regNode(reg), nil
+	}
+	if p.resolve != nil {
+		if addr, ok := p.resolve(t); ok {
+			return constNode(addr), nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестное имя в выражении: %s", t)
+}
@@ -0,0 +1,307 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Device - устройство, регистры которого отображены на адреса памяти данных.
+// Регистр с номером i находится по адресу base + i*WordCells(): при адресации
+// по словам - в соседних словах, при адресации по байтам - через размер слова.
+// Обращения к регистрам устройств не затрагивают память; при выполнении назад
+// отменяется только состояние устройств StatefulDevice.
+type Device interface {
+	Name() string
+	Registers() uint32 // число регистров
+	Read(reg uint32) (uint32, error)
+	Write(reg, value uint32) error
+}
+
+// StatefulDevice - устройство с внутренним состоянием. Состояние входит в историю
+// выполнения и снимки: при выполнении назад и восстановлении снимка оно
+// возвращается вместе с регистрами и памятью.
+type StatefulDevice interface {
+	Device
+	SaveState() []uint32
+	RestoreState(state []uint32) error
+}
+
+// DeviceState - сохраненное состояние устройства, подключенного по адресу Base
+type DeviceState struct {
+	Base  uint32
+	Name  string
+	State []uint32
+}
+
+// DeviceMapping - устройство, подключенное к шине по базовому адресу
+type DeviceMapping struct {
+	Base   uint32
+	Device Device
+}
+
+// TimerLine - линия прерывания стандартного таймера
+const TimerLine = 0
+
+// DefaultDeviceBase - адрес стандартных устройств: последние адреса, которые
+// еще можно загрузить командой LOAD (поле C - 24 бита)
+const DefaultDeviceBase = 0xFFFF00
+
+// Attach подключает устройство по адресу base. Диапазоны адресов устройств
+// не должны пересекаться; память под ними становится недоступна программе.
+func (vm *VM) Attach(base uint32, dev Device) error {
+	end := uint64(base) + uint64(dev.Registers())*uint64(vm.Model.WordCells())
+	if dev.Registers() == 0 || end > 1<<32 {
+		return fmt.Errorf("устройство %s: неверный диапазон адресов", dev.Name())
+	}
+	for _, m := range vm.Devices {
+		mEnd := uint64(m.Base) + uint64(m.Device.Registers())*uint64(vm.Model.WordCells())
+		if uint64(base) < mEnd && uint64(m.Base) < end {
+			return fmt.Errorf("устройство %s по адресу %d пересекается с устройством %s по адресу %d",
+				dev.Name(), base, m.Device.Name(), m.Base)
+		}
+	}
+	vm.Devices = append(vm.Devices, DeviceMapping{Base: base, Device: dev})
+	sort.Slice(vm.Devices, func(i, j int) bool { return vm.Devices[i].Base < vm.Devices[j].Base })
+
+	for i, state := range vm.PendingDevices {
+		if state.Base == base && state.Name == dev.Name() {
+			vm.PendingDevices = append(vm.PendingDevices[:i], vm.PendingDevices[i+1:]...)
+			return vm.RestoreDevices([]DeviceState{state})
+		}
+	}
+	return nil
+}
+
+// SaveDevices возвращает состояния подключенных устройств StatefulDevice
+// в порядке адресов
+func (vm *VM) SaveDevices() []DeviceState {
+	var states []DeviceState
+	for _, m := range vm.Devices {
+		if dev, ok := m.Device.(StatefulDevice); ok {
+			states = append(states, DeviceState{Base: m.Base, Name: dev.Name(), State: dev.SaveState()})
+		}
+	}
+	return states
+}
+
+// RestoreDevices возвращает устройствам сохраненные состояния. Состояние устройства,
+// которое не подключено по тому же адресу, - ошибка.
+func (vm *VM) RestoreDevices(states []DeviceState) error {
+	for _, state := range states {
+		var dev StatefulDevice
+		for _, m := range vm.Devices {
+			if d, ok := m.Device.(StatefulDevice); ok && m.Base == state.Base && d.Name() == state.Name {
+				dev = d
+			}
+		}
+		if dev == nil {
+			return fmt.Errorf("устройство %s по адресу %d не подключено", state.Name, state.Base)
+		}
+		if err := dev.RestoreState(state.State); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkState проверяет число значений в сохраненном состоянии устройства
+func checkState(dev Device, state []uint32, n int) error {
+	if len(state) != n {
+		return fmt.Errorf("устройство %s: неверное состояние (%d значений вместо %d)", dev.Name(), len(state), n)
+	}
+	return nil
+}
+
+// device находит устройство и номер регистра по адресу. Адрес внутри диапазона
+// устройства, не совпадающий с началом регистра, - ошибка.
+func (vm *VM) device(addr uint32) (Device, uint32, bool, error) {
+	cells := vm.Model.WordCells()
+	for _, m := range vm.Devices {
+		if addr < m.Base || uint64(addr) >= uint64(m.Base)+uint64(m.Device.Registers())*uint64(cells) {
+			continue
+		}
+		offset := addr - m.Base
+		if offset%cells != 0 {
+			return nil, 0, true, fmt.Errorf("устройство %s: адрес %d не выровнен по регистру", m.Device.Name(), addr)
+		}
+		return m.Device, offset / cells, true, nil
+	}
+	return nil, 0, false, nil
+}
+
+// ExitRequest возвращается устройством, чтобы завершить программу с кодом Code
+type ExitRequest struct {
+	Code uint32
+}
+
+func (e *ExitRequest) Error() string {
+	return fmt.Sprintf("завершение программы с кодом %d", e.Code)
+}
+
+// ConsoleOut - порт вывода: запись в регистр 0 выводит младший байт значения.
+// Многобайтовые символы UTF-8 выводятся побайтно. После возврата к более раннему
+// состоянию (RestoreState) уже выведенные байты не выводятся повторно.
+type ConsoleOut struct {
+	w       io.Writer
+	written uint64
+	emitted uint64 // байтов, действительно записанных в w
+	last    byte
+}
+
+// NewConsoleOut создает порт вывода в w
+func NewConsoleOut(w io.Writer) *ConsoleOut {
+	return &ConsoleOut{w: w}
+}
+
+func (c *ConsoleOut) Name() string      { return "console-out" }
+func (c *ConsoleOut) Registers() uint32 { return 1 }
+
+// Read возвращает число выведенных байтов
+func (c *ConsoleOut) Read(reg uint32) (uint32, error) {
+	return uint32(c.written), nil
+}
+
+func (c *ConsoleOut) Write(reg, value uint32) error {
+	c.last = byte(value)
+	c.written++
+	if c.written <= c.emitted {
+		return nil
+	}
+	c.emitted = c.written
+	_, err := c.w.Write([]byte{c.last})
+	return err
+}
+
+// SaveState возвращает число выведенных байтов и последний байт
+func (c *ConsoleOut) SaveState() []uint32 {
+	return []uint32{uint32(c.written), uint32(c.written >> 32), uint32(c.last)}
+}
+
+func (c *ConsoleOut) RestoreState(state []uint32) error {
+	if err := checkState(c, state, 3); err != nil {
+		return err
+	}
+	c.written = uint64(state[0]) | uint64(state[1])<<32
+	c.last = byte(state[2])
+	return nil
+}
+
+// Written возвращает число выведенных байтов
+func (c *ConsoleOut) Written() uint64 {
+	return c.written
+}
+
+// EndsLine сообщает, закончился ли вывод переводом строки (или его не было)
+func (c *ConsoleOut) EndsLine() bool {
+	return c.written == 0 || c.last == '\n'
+}
+
+// ConsoleIn - порт ввода: чтение регистра 0 возвращает очередной байт ввода
+// или 0xFFFFFFFF в конце ввода. Запись игнорируется. Прочитанные байты хранятся,
+// чтобы после возврата к более раннему состоянию ввод повторился без нового чтения.
+type ConsoleIn struct {
+	r       *bufio.Reader
+	history []byte // байты, прочитанные из r
+	pos     int    // позиция следующего байта в history
+}
+
+// ConsoleEOF - значение, которое порт ввода возвращает в конце ввода
+const ConsoleEOF = 0xFFFFFFFF
+
+// NewConsoleIn создает порт ввода из r
+func NewConsoleIn(r io.Reader) *ConsoleIn {
+	return &ConsoleIn{r: bufio.NewReader(r)}
+}
+
+func (c *ConsoleIn) Name() string      { return "console-in" }
+func (c *ConsoleIn) Registers() uint32 { return 1 }
+
+func (c *ConsoleIn) Read(reg uint32) (uint32, error) {
+	if c.pos == len(c.history) {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			return ConsoleEOF, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		c.history = append(c.history, b)
+	}
+	c.pos++
+	return uint32(c.history[c.pos-1]), nil
+}
+
+// SaveState возвращает число прочитанных байтов ввода
+func (c *ConsoleIn) SaveState() []uint32 {
+	return []uint32{uint32(c.pos)}
+}
+
+// RestoreState возвращается к сохраненной позиции ввода. Если ввод еще не прочитан
+// до нее (снимок другого запуска), байты до позиции пропускаются.
+func (c *ConsoleIn) RestoreState(state []uint32) error {
+	if err := checkState(c, state, 1); err != nil {
+		return err
+	}
+	pos := int(state[0])
+	for len(c.history) < pos {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		c.history = append(c.history, b)
+	}
+	c.pos = min(pos, len(c.history))
+	return nil
+}
+
+func (c *ConsoleIn) Write(reg, value uint32) error {
+	return nil
+}
+
+// HaltPort - порт останова: запись значения завершает программу с этим кодом
+type HaltPort struct{}
+
+func (HaltPort) Name() string      { return "halt" }
+func (HaltPort) Registers() uint32 { return 1 }
+
+func (HaltPort) Read(reg uint32) (uint32, error) {
+	return 0, nil
+}
+
+func (HaltPort) Write(reg, value uint32) error {
+	return &ExitRequest{Code: value}
+}
+
+// AttachStandardDevices подключает стандартные устройства с адреса base:
+// вывод (base), ввод (base + слово), останов (base + 2 слова), контроллер
+// прерываний (base + 3..8 слов) и таймер на линии TimerLine (base + 9..11 слов).
+// Если in равен nil, порт ввода не подключается, и его адрес остается обычной памятью.
+func (vm *VM) AttachStandardDevices(base uint32, in io.Reader, out io.Writer) (*ConsoleOut, error) {
+	cells := vm.Model.WordCells()
+	console := NewConsoleOut(out)
+	if err := vm.Attach(base, console); err != nil {
+		return nil, err
+	}
+	if in != nil {
+		if err := vm.Attach(base+cells, NewConsoleIn(in)); err != nil {
+			return nil, err
+		}
+	}
+	if err := vm.Attach(base+2*cells, HaltPort{}); err != nil {
+		return nil, err
+	}
+
+	ic := NewInterruptController()
+	if err := vm.Attach(base+3*cells, ic); err != nil {
+		return nil, err
+	}
+	if err := vm.Attach(base+(3+intRegisters)*cells, NewTimer(ic, TimerLine)); err != nil {
+		return nil, err
+	}
+	return console, nil
+}
@@ -0,0 +1,159 @@
+package emulator
+
+import (
+	"context"
+	"uvm-assembler/assembler"
+)
+
+// Instruction - предекодированная команда. Поля названы по смыслу, а не по формату:
+//
+//	LOAD  R[X] = Imm
+//	READ  R[X] = mem[R[Y] + Imm]
+//	WRITE mem[R[Y]] = R[X]
+//	SQRT  mem[Imm] = sqrt(R[X])
+//
+// Valid = false - в слове нет известного кода операции, такую команду выполняет Step.
+type Instruction struct {
+	Op    assembler.CommandType
+	X, Y  uint8
+	Imm   uint32
+	Valid bool
+}
+
+// Predecode декодирует код программы в массив команд. Поля извлекаются
+// по раскладке напрямую, без промежуточной assembler.Command.
+func Predecode(code []byte) []Instruction {
+	prog := make([]Instruction, len(code)/assembler.CommandSize)
+	for i := range prog {
+		word := code[i*assembler.CommandSize:]
+		op := assembler.CommandType(assembler.ExtractField(word, assembler.OpcodeField))
+		layout, ok := assembler.Layout(op)
+		if !ok {
+			continue
+		}
+
+		in := Instruction{Op: op, Valid: true}
+		for _, f := range layout[1:] {
+			value := assembler.ExtractField(word, f)
+			switch {
+			case f.Field == assembler.FieldD || (f.Field == assembler.FieldB && op != assembler.READ_MEM):
+				in.X = uint8(value)
+			case f.Field == assembler.FieldC && (op == assembler.READ_MEM || op == assembler.WRITE_MEM):
+				in.Y = uint8(value)
+			default:
+				in.Imm = value
+			}
+		}
+		prog[i] = in
+	}
+	return prog
+}
+
+// RunFast выполняет программу так же, как RunContext, но по предекодированным командам.
+// При адресации по словам с ошибкой за границами памяти команды выполняются напрямую,
+// без декодирования и трассировки; неверные команды, другие модели памяти и выход PC
+// за программу обрабатывает обычный Step. Результат, вывод и ошибки совпадают с RunContext.
+// С трассировщиком или профилем RunFast просто вызывает RunContext.
+func (vm *VM) RunFast(ctx context.Context, limits Limits) error {
+	if vm.Tracer != nil || vm.Profile != nil {
+		return vm.RunContext(ctx, limits)
+	}
+	l, err := newLimiter(ctx, limits, len(vm.Memory), vm.Steps)
+	if err != nil {
+		return err
+	}
+
+	prog := Predecode(vm.Code)
+	direct := vm.Model.WordCells() == 1 && vm.Model.OutOfBounds == TrapOutOfBounds
+	// Адреса ниже memEnd - обычная память, остальные - через load и store
+	memEnd := uint32(len(vm.Memory))
+	var tickers []Ticker
+	var interrupters []Interrupter
+	for _, m := range vm.Devices {
+		memEnd = min(memEnd, m.Base)
+		if t, ok := m.Device.(Ticker); ok {
+			tickers = append(tickers, t)
+		}
+		if i, ok := m.Device.(Interrupter); ok {
+			interrupters = append(interrupters, i)
+		}
+	}
+	mask, end := vm.Model.mask(), uint32(len(prog))
+
+	for !vm.Halted {
+		if err := l.check(vm.Steps); err != nil {
+			return err
+		}
+		if !direct || vm.PC >= end || !prog[vm.PC].Valid {
+			if err := vm.Step(); err != nil {
+				return l.stopped(err, vm.Steps)
+			}
+			continue
+		}
+
+		if err := vm.fastStep(&prog[vm.PC], memEnd, mask); err != nil {
+			cmd, _ := vm.Fetch(vm.PC)
+			return l.stopped(vm.classify(cmd, err), vm.Steps)
+		}
+		if vm.jump != nil {
+			vm.PC, vm.jump = *vm.jump, nil
+		} else {
+			vm.PC++
+		}
+		vm.Steps++
+
+		if !vm.Exited {
+			for _, t := range tickers {
+				t.Tick()
+			}
+			for _, i := range interrupters {
+				if err := vm.interrupt(i); err != nil {
+					return err
+				}
+			}
+		}
+		vm.Halted = vm.PC == end || vm.Exited
+	}
+	return nil
+}
+
+// fastStep выполняет команду: обращения к обычной памяти - напрямую,
+// к устройствам и за границы памяти - через load и store
+func (vm *VM) fastStep(in *Instruction, memEnd, mask uint32) error {
+	switch in.Op {
+	case assembler.LOAD_CONST:
+		vm.Regs[in.X] = in.Imm
+	case assembler.READ_MEM:
+		addr := vm.Regs[in.Y] + in.Imm
+		if addr < memEnd {
+			vm.Regs[in.X] = vm.Memory[addr] & mask
+			return nil
+		}
+		vm.Accesses = vm.Accesses[:0]
+		value, err := vm.load(addr)
+		if err != nil {
+			return err
+		}
+		vm.Regs[in.X] = value
+	case assembler.WRITE_MEM:
+		addr := vm.Regs[in.Y]
+		if addr < memEnd {
+			vm.Memory[addr] = vm.Regs[in.X] & mask
+			return nil
+		}
+		vm.Accesses = vm.Accesses[:0]
+		return vm.store(addr, vm.Regs[in.X])
+	case assembler.SQRT_OP:
+		value, err := Sqrt(vm.SqrtMode, vm.SqrtSigned, vm.Regs[in.X])
+		if err != nil {
+			return err
+		}
+		if in.Imm < memEnd {
+			vm.Memory[in.Imm] = value & mask
+			return nil
+		}
+		vm.Accesses = vm.Accesses[:0]
+		return vm.store(in.Imm, value)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"uvm-assembler/assembler"
+)
+
+// corpusSteps - лимит команд для программ набора, которые не завершаются
+const corpusSteps = 1000000
+
+// corpusProgram - программа набора для сравнения быстрого ядра с интерпретатором
+type corpusProgram struct {
+	name  string
+	image *assembler.Image
+}
+
+// runResult - состояние машины и вывод после выполнения программы
+type runResult struct {
+	vm     *VM
+	output []byte
+	err    string
+}
+
+// corpus собирает набор программ: случайные смеси команд, программу с частыми
+// обращениями к памяти, программу из SQRT, бесконечный цикл на прерываниях с выводом
+// и смеси, которые заканчиваются ошибкой выполнения и записью в порт останова
+func corpus(tb testing.TB, length int) []corpusProgram {
+	tb.Helper()
+	rng := rand.New(rand.NewSource(1))
+	reg := func() int { return 1 + rng.Intn(60) }
+
+	generate := func(weights [4]int) string {
+		var src strings.Builder
+		total := weights[0] + weights[1] + weights[2] + weights[3]
+		for i := 0; i < length; i++ {
+			switch k := rng.Intn(total); {
+			case k < weights[0]:
+				fmt.Fprintf(&src, "LOAD R%d %d\n", reg(), rng.Intn(1<<24))
+			case k < weights[0]+weights[1]:
+				fmt.Fprintf(&src, "LOAD R61 %d\nREAD R%d %d R61\n", rng.Intn(2048), reg(), rng.Intn(2048))
+			case k < weights[0]+weights[1]+weights[2]:
+				fmt.Fprintf(&src, "LOAD R62 %d\nWRITE R%d R62\n", rng.Intn(4096), reg())
+			default:
+				fmt.Fprintf(&src, "SQRT R%d %d\n", reg(), rng.Intn(4096))
+			}
+		}
+		return src.String()
+	}
+
+	sources := []struct{ name, text string }{
+		{"mix", generate([4]int{1, 1, 1, 1})},
+		{"memory", generate([4]int{0, 2, 2, 0})},
+		{"sqrt", generate([4]int{1, 0, 0, 3})},
+		{"interrupts", interruptLoop},
+		{"fault", generate([4]int{1, 1, 1, 1}) + "LOAD R1 5000\nREAD R2 0 R1\n"},
+		{"exit", generate([4]int{1, 1, 1, 1}) + "LOAD R1 3\nLOAD R2 0xFFFF02\nWRITE R1 R2\nLOAD R1 4\n"},
+	}
+
+	var programs []corpusProgram
+	for _, s := range sources {
+		program, err := assembler.NewParser(s.text).ParseProgram()
+		if err != nil {
+			tb.Fatalf("%s: %v", s.name, err)
+		}
+		img, err := program.Image()
+		if err != nil {
+			tb.Fatalf("%s: %v", s.name, err)
+		}
+		programs = append(programs, corpusProgram{name: s.name, image: img})
+	}
+	return programs
+}
+
+// newCorpusMachine создает машину для программы набора: 4096 слов памяти
+// и стандартные устройства с выводом в out и пустым вводом
+func newCorpusMachine(tb testing.TB, img *assembler.Image, out *bytes.Buffer) *VM {
+	tb.Helper()
+	model := DefaultMemoryModel()
+	model.Size = 4096
+	vm, err := NewWithModel(img, model)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := vm.AttachStandardDevices(DefaultDeviceBase, bytes.NewReader(nil), out); err != nil {
+		tb.Fatal(err)
+	}
+	return vm
+}
+
+func interpret(vm *VM) error {
+	return vm.RunContext(context.Background(), Limits{Steps: corpusSteps})
+}
+
+func runFast(vm *VM) error {
+	return vm.RunFast(context.Background(), Limits{Steps: corpusSteps})
+}
+
+func runCorpus(tb testing.TB, img *assembler.Image, run func(vm *VM) error) runResult {
+	var out bytes.Buffer
+	vm := newCorpusMachine(tb, img, &out)
+	result := runResult{vm: vm}
+	if err := run(vm); err != nil {
+		result.err = err.Error()
+	}
+	result.output = out.Bytes()
+	return result
+}
+
+// diff описывает первое различие результатов или возвращает пустую строку
+func diff(a, b runResult) string {
+	switch {
+	case a.err != b.err:
+		return fmt.Sprintf("ошибка %q и %q", a.err, b.err)
+	case a.vm.Steps != b.vm.Steps:
+		return fmt.Sprintf("выполнено %d и %d команд", a.vm.Steps, b.vm.Steps)
+	case a.vm.PC != b.vm.PC:
+		return fmt.Sprintf("PC %d и %d", a.vm.PC, b.vm.PC)
+	case a.vm.Halted != b.vm.Halted || a.vm.Exited != b.vm.Exited || a.vm.ExitCode != b.vm.ExitCode:
+		return "разное состояние завершения"
+	case !bytes.Equal(a.output, b.output):
+		return "разный вывод"
+	}
+	for i := range a.vm.Regs {
+		if a.vm.Regs[i] != b.vm.Regs[i] {
+			return fmt.Sprintf("R%d = %d и %d", i, a.vm.Regs[i], b.vm.Regs[i])
+		}
+	}
+	for i := range a.vm.Memory {
+		if a.vm.Memory[i] != b.vm.Memory[i] {
+			return fmt.Sprintf("ячейка %d = %d и %d", i, a.vm.Memory[i], b.vm.Memory[i])
+		}
+	}
+	return ""
+}
+
+// TestFastMatchesInterpreter проверяет, что быстрое ядро дает тот же результат,
+// что и интерпретатор: регистры, память, PC, число команд, вывод и ошибку
+func TestFastMatchesInterpreter(t *testing.T) {
+	for _, p := range corpus(t, 4096) {
+		t.Run(p.name, func(t *testing.T) {
+			want, got := runCorpus(t, p.image, interpret), runCorpus(t, p.image, runFast)
+			if d := diff(want, got); d != "" {
+				t.Errorf("результаты различаются: %s", d)
+			}
+		})
+	}
+}
+
+// benchmarkCorpus измеряет выполнение программ набора; ns/instr - время на одну команду
+func benchmarkCorpus(b *testing.B, run func(vm *VM) error) {
+	for _, p := range corpus(b, 4096) {
+		b.Run(p.name, func(b *testing.B) {
+			var out bytes.Buffer
+			steps := uint64(0)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				out.Reset()
+				vm := newCorpusMachine(b, p.image, &out)
+				b.StartTimer()
+				run(vm)
+				steps += vm.Steps
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(max(steps, 1)), "ns/instr")
+		})
+	}
+}
+
+func BenchmarkRunContext(b *testing.B) {
+	benchmarkCorpus(b, interpret)
+}
+
+func BenchmarkRunFast(b *testing.B) {
+	benchmarkCorpus(b, runFast)
+}
+
+// interruptLoop - бесконечная программа на прерываниях: обработчик линии 1
+// не сбрасывает ее и выполняется снова после каждого IRET, периодический таймер
+// на линии 0 прерывает его и выводит точку. Программу останавливает лимит команд.
+const interruptLoop = `
+.data
+vectors: .word tick, work
+.text
+    LOAD R1 vectors
+    LOAD R2 0xFFFF05
+    WRITE R1 R2          ; VECTORS
+    LOAD R1 3
+    LOAD R2 0xFFFF03
+    WRITE R1 R2          ; разрешить линии 0 и 1
+    LOAD R1 50
+    LOAD R2 0xFFFF09
+    WRITE R1 R2          ; период таймера
+    LOAD R1 3
+    LOAD R2 0xFFFF0B
+    WRITE R1 R2          ; включить периодический таймер
+    LOAD R1 1
+    LOAD R3 100
+    LOAD R2 0xFFFF08
+    WRITE R1 R2          ; поднять линию 1
+work:
+    WRITE R3 R3
+    READ R5 0 R3
+    SQRT R5 101
+    WRITE R5 R3
+    READ R6 1 R3
+    WRITE R6 R3
+    READ R7 0 R3
+    SQRT R7 102
+    LOAD R20 0xFFFF07
+    WRITE R1 R20         ; IRET, линия 1 остается поднятой
+tick:
+    LOAD R22 0xFFFF00
+    LOAD R23 46
+    WRITE R23 R22        ; вывести точку
+    LOAD R22 0xFFFF04
+    LOAD R24 1
+    WRITE R24 R22        ; сбросить линию 0
+    LOAD R20 0xFFFF07
+    WRITE R1 R20         ; IRET
+`
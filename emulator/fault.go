@@ -0,0 +1,116 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+	"uvm-assembler/assembler"
+)
+
+// FaultKind - вид ошибки выполнения программы
+type FaultKind int
+
+const (
+	FaultInvalidOpcode FaultKind = iota + 1 // в поле A нет известного кода операции
+	FaultMemory                             // обращение за пределы памяти данных
+	FaultSqrt                               // недопустимый аргумент SQRT
+	FaultPC                                 // PC указывает за конец программы
+)
+
+var faultNames = map[FaultKind]string{
+	FaultInvalidOpcode: "неверный код операции",
+	FaultMemory:        "обращение за пределы памяти",
+	FaultSqrt:          "недопустимый аргумент SQRT",
+	FaultPC:            "PC за концом программы",
+}
+
+func (k FaultKind) String() string {
+	if name, ok := faultNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ошибка %d", int(k))
+}
+
+// ExitCode возвращает код завершения процесса для ошибки этого вида.
+// Коды 1 (прочие ошибки) и 2 (неверные аргументы) не используются.
+func (k FaultKind) ExitCode() int {
+	return 10 + int(k)
+}
+
+// MarshalText позволяет записывать вид ошибки в JSON строкой
+func (k FaultKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Fault - ошибка выполнения с местом, где она произошла. Машина остается
+// в состоянии перед ошибочной командой: PC не увеличивается.
+type Fault struct {
+	Kind FaultKind `json:"kind"`
+	PC   uint32    `json:"pc"`
+	Step uint64    `json:"step"`
+	// Instruction - дизассемблированная команда; если команду не удалось
+	// декодировать - ее байты в шестнадцатеричном виде
+	Instruction string                    `json:"instruction,omitempty"`
+	Source      *assembler.SourceLocation `json:"source,omitempty"`
+	Err         error                     `json:"-"`
+}
+
+func (f *Fault) Error() string {
+	text := fmt.Sprintf("адрес %d: ", f.PC)
+	if f.Instruction != "" {
+		text += f.Instruction + ": "
+	}
+	text += f.Err.Error()
+	if f.Source != nil {
+		text += fmt.Sprintf(" (%s)", f.Source)
+	}
+	return text
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Err
+}
+
+// MemoryError - обращение за пределы памяти данных
+type MemoryError struct {
+	Kind AccessKind
+	Addr uint32
+	Size int
+}
+
+func (e *MemoryError) Error() string {
+	what := "чтение"
+	if e.Kind == AccessWrite {
+		what = "запись"
+	}
+	return fmt.Sprintf("%s за пределами памяти: адрес %d, размер %d", what, e.Addr, e.Size)
+}
+
+// SqrtError - аргумент SQRT вне области определения
+type SqrtError struct {
+	Value uint32
+	Mode  SqrtMode
+}
+
+func (e *SqrtError) Error() string {
+	if e.Mode == SqrtFloat {
+		return fmt.Sprintf("корень из отрицательного или недопустимого значения: 0x%08X (%g)", e.Value, math.Float32frombits(e.Value))
+	}
+	return fmt.Sprintf("корень из отрицательного или недопустимого значения: 0x%08X (%d со знаком)", e.Value, int32(e.Value))
+}
+
+// fault создает ошибку выполнения для текущей команды
+func (vm *VM) fault(kind FaultKind, cmd *assembler.Command, err error) *Fault {
+	f := &Fault{Kind: kind, PC: vm.PC, Step: vm.Steps, Err: err}
+	switch {
+	case cmd != nil:
+		f.Instruction = assembler.Disassemble(*cmd)
+	case kind == FaultInvalidOpcode && vm.PC < vm.Len():
+		// Команду не удалось декодировать: показываем ее байты
+		start := int(vm.PC) * assembler.CommandSize
+		f.Instruction = fmt.Sprintf("% X", vm.Code[start:start+assembler.CommandSize])
+	}
+	if loc, ok := vm.Debug.Lookup(vm.PC); ok {
+		f.Source = &loc.SourceLocation
+	}
+	return f
+}
@@ -0,0 +1,173 @@
+package emulator
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// HeatmapCounter - какие обращения показывает тепловая карта
+type HeatmapCounter string
+
+const (
+	HeatmapAll   HeatmapCounter = "all"   // чтения и записи вместе
+	HeatmapRead  HeatmapCounter = "read"  // только чтения
+	HeatmapWrite HeatmapCounter = "write" // только записи
+	HeatmapSplit HeatmapCounter = "split" // записи - красный канал, чтения - синий
+)
+
+// HeatmapCounters - допустимые значения HeatmapCounter
+var HeatmapCounters = []string{string(HeatmapAll), string(HeatmapRead), string(HeatmapWrite), string(HeatmapSplit)}
+
+// maxHeatmapPixels ограничивает размер изображения
+const maxHeatmapPixels = 1 << 26
+
+// HeatmapOptions - параметры тепловой карты обращений к памяти
+type HeatmapOptions struct {
+	From, To uint32 // диапазон адресов ячеек, включительно
+	RowWidth int    // ячеек в строке изображения
+	CellSize int    // сторона квадрата ячейки в пикселях
+	Counter  HeatmapCounter
+}
+
+// heatmapStops - шкала цветов от редких обращений к частым
+var heatmapStops = []color.RGBA{
+	{0, 0, 128, 255},
+	{0, 128, 255, 255},
+	{0, 220, 120, 255},
+	{255, 230, 0, 255},
+	{255, 40, 0, 255},
+}
+
+// heatmapUnused - цвет ячеек без обращений
+var heatmapUnused = color.RGBA{32, 32, 32, 255}
+
+// MemoryRange возвращает наименьший и наибольший адрес ячеек, к которым были обращения
+func (p *Profile) MemoryRange() (from, to uint32, ok bool) {
+	for _, counts := range []map[uint32]uint64{p.MemReads, p.MemWrites} {
+		for addr := range counts {
+			if !ok || addr < from {
+				from = addr
+			}
+			if !ok || addr > to {
+				to = addr
+			}
+			ok = true
+		}
+	}
+	return from, to, ok
+}
+
+// Heatmap рисует счетчики обращений к ячейкам памяти: ячейка From - левый верхний
+// квадрат, строка содержит RowWidth ячеек (меньше, если весь диапазон короче).
+// Цвет меняется логарифмически от 1 до наибольшего числа обращений в диапазоне,
+// ячейки без обращений - темно-серые. Возвращает изображение и наибольшее число обращений.
+func (p *Profile) Heatmap(opts HeatmapOptions) (*image.RGBA, uint64, error) {
+	if opts.RowWidth <= 0 || opts.CellSize <= 0 {
+		return nil, 0, fmt.Errorf("ширина строки и размер ячейки должны быть положительными")
+	}
+	if opts.To < opts.From {
+		return nil, 0, fmt.Errorf("пустой диапазон адресов: %d..%d", opts.From, opts.To)
+	}
+	if opts.Counter == "" {
+		opts.Counter = HeatmapAll
+	}
+
+	var count func(addr uint32) (reads, writes uint64)
+	switch opts.Counter {
+	case HeatmapAll, HeatmapSplit:
+		count = func(addr uint32) (uint64, uint64) { return p.MemReads[addr], p.MemWrites[addr] }
+	case HeatmapRead:
+		count = func(addr uint32) (uint64, uint64) { return p.MemReads[addr], 0 }
+	case HeatmapWrite:
+		count = func(addr uint32) (uint64, uint64) { return 0, p.MemWrites[addr] }
+	default:
+		return nil, 0, fmt.Errorf("неизвестный вид тепловой карты: %s (допустимо: %s)", opts.Counter, strings.Join(HeatmapCounters, ", "))
+	}
+
+	cells := uint64(opts.To) - uint64(opts.From) + 1
+	if cells < uint64(opts.RowWidth) {
+		opts.RowWidth = int(cells)
+	}
+	rows := (cells + uint64(opts.RowWidth) - 1) / uint64(opts.RowWidth)
+	width, height := uint64(opts.RowWidth*opts.CellSize), rows*uint64(opts.CellSize)
+	if width*height > maxHeatmapPixels {
+		return nil, 0, fmt.Errorf("изображение %dx%d слишком велико: уменьшите диапазон или размер ячейки", width, height)
+	}
+
+	var maxReads, maxWrites, maxTotal uint64
+	for addr := uint64(opts.From); addr <= uint64(opts.To); addr++ {
+		r, w := count(uint32(addr))
+		maxReads, maxWrites, maxTotal = max(maxReads, r), max(maxWrites, w), max(maxTotal, r+w)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	for i := uint64(0); i < rows*uint64(opts.RowWidth); i++ {
+		c := color.RGBA{0, 0, 0, 255} // за концом диапазона
+		if i < cells {
+			r, w := count(uint32(uint64(opts.From) + i))
+			switch {
+			case r+w == 0:
+				c = heatmapUnused
+			case opts.Counter == HeatmapSplit:
+				c = color.RGBA{heatLevel(w, maxWrites), 0, heatLevel(r, maxReads), 255}
+			default:
+				c = heatColor(logScale(r+w, maxTotal))
+			}
+		}
+
+		x0 := int(i%uint64(opts.RowWidth)) * opts.CellSize
+		y0 := int(i/uint64(opts.RowWidth)) * opts.CellSize
+		for y := y0; y < y0+opts.CellSize; y++ {
+			for x := x0; x < x0+opts.CellSize; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	if opts.Counter == HeatmapSplit {
+		maxTotal = max(maxReads, maxWrites)
+	}
+	return img, maxTotal, nil
+}
+
+// WriteHeatmap записывает тепловую карту в формате PNG и возвращает наибольшее число обращений
+func (p *Profile) WriteHeatmap(w io.Writer, opts HeatmapOptions) (uint64, error) {
+	img, peak, err := p.Heatmap(opts)
+	if err != nil {
+		return 0, err
+	}
+	return peak, png.Encode(w, img)
+}
+
+// logScale переводит число обращений в долю от 0 до 1 по логарифмической шкале
+func logScale(n, peak uint64) float64 {
+	if peak <= 1 {
+		return 1
+	}
+	return math.Log(float64(n)) / math.Log(float64(peak))
+}
+
+// heatLevel - яркость канала для режима split; любое обращение заметно (не меньше 64)
+func heatLevel(n, peak uint64) uint8 {
+	if n == 0 {
+		return 0
+	}
+	return uint8(64 + 191*logScale(n, peak))
+}
+
+// heatColor интерполирует цвет шкалы для доли t от 0 до 1
+func heatColor(t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t)) * float64(len(heatmapStops)-1)
+	i := int(t)
+	if i >= len(heatmapStops)-1 {
+		return heatmapStops[len(heatmapStops)-1]
+	}
+	a, b, f := heatmapStops[i], heatmapStops[i+1], t-float64(i)
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
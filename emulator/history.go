@@ -0,0 +1,250 @@
+package emulator
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	DefaultHistoryLimit       = 100000 // команд, которые можно отменить без повторного выполнения
+	DefaultCheckpointInterval = 1000   // команд между контрольными точками
+	DefaultMaxCheckpoints     = 64
+)
+
+// Delta - изменения состояния, внесенные одной командой, достаточные для ее отмены
+type Delta struct {
+	Step     uint64
+	PC       uint32
+	Regs     []RegChange
+	Accesses []Access
+	Devices  []DeviceState // состояния устройств до команды, если она их изменила
+}
+
+// checkpoint - полная копия состояния машины перед выполнением команды Step
+type checkpoint struct {
+	step    uint64
+	pc      uint32
+	regs    [RegisterCount]uint32
+	memory  []uint32
+	devices []DeviceState
+}
+
+// Recorder записывает историю выполнения, чтобы можно было двигаться назад.
+// Последние команды отменяются по записанным изменениям, более ранние состояния
+// восстанавливаются из ближайшей контрольной точки и повторного выполнения.
+// Память ограничена: хранится не более Limit изменений и MaxCheckpoints
+// контрольных точек (начальная точка хранится всегда).
+type Recorder struct {
+	vm          *VM
+	deltas      []Delta
+	checkpoints []checkpoint
+	devices     []DeviceState // текущие состояния устройств
+
+	Limit          int
+	Interval       uint64 // команд между контрольными точками; 0 - только начальная точка
+	MaxCheckpoints int
+}
+
+// NewRecorder начинает запись истории с текущего состояния машины.
+// Если позже к машине подключается устройство с состоянием, история
+// начинается заново со следующей команды.
+func NewRecorder(vm *VM) *Recorder {
+	r := &Recorder{
+		vm:             vm,
+		Limit:          DefaultHistoryLimit,
+		Interval:       DefaultCheckpointInterval,
+		MaxCheckpoints: DefaultMaxCheckpoints,
+	}
+	r.Reset()
+	return r
+}
+
+// Reset забывает историю и начинает запись с текущего состояния.
+// Вызывается после изменения состояния извне (например, командой set отладчика).
+func (r *Recorder) Reset() {
+	r.deltas = nil
+	r.checkpoints = []checkpoint{r.capture()}
+	r.devices = r.checkpoints[0].devices
+}
+
+// Oldest возвращает номер самого раннего доступного шага истории
+func (r *Recorder) Oldest() uint64 {
+	return r.checkpoints[0].step
+}
+
+// Step выполняет одну команду и записывает ее изменения
+func (r *Recorder) Step() error {
+	vm := r.vm
+	if vm.Halted {
+		return vm.Step()
+	}
+
+	if !sameDevices(vm.SaveDevices(), r.devices) {
+		// Подключено новое устройство: его прежнее состояние неизвестно
+		r.Reset()
+	}
+	if r.Interval > 0 && vm.Steps%r.Interval == 0 && vm.Steps > r.checkpoints[len(r.checkpoints)-1].step {
+		r.addCheckpoint()
+	}
+
+	before := vm.Regs
+	delta := Delta{Step: vm.Steps, PC: vm.PC}
+	if err := vm.Step(); err != nil {
+		return err
+	}
+
+	for i := range vm.Regs {
+		if vm.Regs[i] != before[i] {
+			delta.Regs = append(delta.Regs, RegChange{Reg: i, Old: before[i], New: vm.Regs[i]})
+		}
+	}
+	delta.Accesses = append([]Access(nil), vm.Accesses...)
+	devices := vm.SaveDevices()
+	for i := range devices {
+		if !slices.Equal(devices[i].State, r.devices[i].State) {
+			delta.Devices = append(delta.Devices, r.devices[i])
+		}
+	}
+	r.devices = devices
+
+	r.deltas = append(r.deltas, delta)
+	if len(r.deltas) > 2*r.Limit {
+		r.deltas = append([]Delta(nil), r.deltas[len(r.deltas)-r.Limit:]...)
+	}
+	return nil
+}
+
+// Back отменяет последнюю выполненную команду и возвращает ее изменения
+func (r *Recorder) Back() (Delta, error) {
+	vm := r.vm
+	if vm.Steps <= r.Oldest() {
+		return Delta{}, fmt.Errorf("достигнуто начало записанной истории (шаг %d)", r.Oldest())
+	}
+
+	if n := len(r.deltas); n == 0 || r.deltas[n-1].Step != vm.Steps-1 {
+		// Изменения последней команды уже вытеснены из памяти: восстанавливаем
+		// состояние перед ней и выполняем ее снова, чтобы записать изменения
+		if err := r.replay(vm.Steps - 1); err != nil {
+			return Delta{}, err
+		}
+		if err := r.Step(); err != nil {
+			return Delta{}, err
+		}
+	}
+
+	delta := r.deltas[len(r.deltas)-1]
+	r.deltas = r.deltas[:len(r.deltas)-1]
+	return delta, r.undo(delta)
+}
+
+// Goto переводит машину в состояние перед выполнением команды с номером step.
+// Переход вперед выполняет программу, переход назад использует историю.
+func (r *Recorder) Goto(step uint64) error {
+	vm := r.vm
+	if step < r.Oldest() {
+		return fmt.Errorf("шаг %d раньше начала записанной истории (шаг %d)", step, r.Oldest())
+	}
+
+	for vm.Steps > step {
+		n := len(r.deltas)
+		if n == 0 || r.deltas[n-1].Step != vm.Steps-1 {
+			return r.replay(step)
+		}
+		if err := r.undo(r.deltas[n-1]); err != nil {
+			return err
+		}
+		r.deltas = r.deltas[:n-1]
+	}
+
+	for vm.Steps < step {
+		if vm.Halted {
+			return fmt.Errorf("программа завершилась на шаге %d", vm.Steps)
+		}
+		if err := r.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undo отменяет изменения одной команды
+func (r *Recorder) undo(d Delta) error {
+	vm := r.vm
+	if len(d.Devices) > 0 {
+		if err := vm.RestoreDevices(d.Devices); err != nil {
+			return err
+		}
+		r.devices = vm.SaveDevices()
+	}
+	for i := len(d.Accesses) - 1; i >= 0; i-- {
+		if a := d.Accesses[i]; a.Kind == AccessWrite && a.Device == "" {
+			vm.poke(a.Addr, a.Old)
+		}
+	}
+	for _, change := range d.Regs {
+		vm.Regs[change.Reg] = change.Old
+	}
+	vm.PC = d.PC
+	vm.Steps = d.Step
+	vm.Halted = false
+	vm.Exited = false
+	vm.Accesses = vm.Accesses[:0]
+	return nil
+}
+
+// replay восстанавливает ближайшую контрольную точку не позже step
+// и выполняет программу до шага step
+func (r *Recorder) replay(step uint64) error {
+	cp := r.checkpoints[0]
+	for _, c := range r.checkpoints {
+		if c.step <= step {
+			cp = c
+		}
+	}
+
+	vm := r.vm
+	if err := vm.RestoreDevices(cp.devices); err != nil {
+		return err
+	}
+	r.devices = cp.devices
+	vm.PC = cp.pc
+	vm.Regs = cp.regs
+	copy(vm.Memory, cp.memory)
+	vm.Steps = cp.step
+	vm.Halted = vm.PC == vm.Len()
+	vm.Exited = false
+	vm.Accesses = vm.Accesses[:0]
+	r.deltas = nil
+
+	for vm.Steps < step {
+		if err := r.Step(); err != nil {
+			return fmt.Errorf("повторное выполнение до шага %d: %v", step, err)
+		}
+	}
+	return nil
+}
+
+// addCheckpoint сохраняет контрольную точку, вытесняя старые (кроме начальной)
+func (r *Recorder) addCheckpoint() {
+	r.checkpoints = append(r.checkpoints, r.capture())
+	if len(r.checkpoints) > r.MaxCheckpoints {
+		r.checkpoints = append(r.checkpoints[:1], r.checkpoints[2:]...)
+	}
+}
+
+func (r *Recorder) capture() checkpoint {
+	return checkpoint{
+		step:    r.vm.Steps,
+		pc:      r.vm.PC,
+		regs:    r.vm.Regs,
+		memory:  append([]uint32(nil), r.vm.Memory...),
+		devices: r.vm.SaveDevices(),
+	}
+}
+
+// sameDevices проверяет, что состояния относятся к одним и тем же устройствам
+func sameDevices(a, b []DeviceState) bool {
+	return slices.EqualFunc(a, b, func(x, y DeviceState) bool {
+		return x.Base == y.Base && x.Name == y.Name
+	})
+}
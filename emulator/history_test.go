@@ -0,0 +1,56 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecorderWithoutCheckpoints(t *testing.T) {
+	vm := newTestMachine(t, "LOAD R1 1\nLOAD R2 2\nLOAD R3 3\n")
+	r := NewRecorder(vm)
+	r.Interval = 0
+	r.Limit = 1
+	for i := 0; i < 3; i++ {
+		if err := r.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Goto(1); err != nil {
+		t.Fatal(err)
+	}
+	if vm.PC != 1 || vm.Regs[1] != 1 || vm.Regs[2] != 0 || vm.Regs[3] != 0 {
+		t.Errorf("PC = %d, R1-R3 = %v, ожидалось PC = 1, R1-R3 = [1 0 0]", vm.PC, vm.Regs[1:4])
+	}
+}
+
+func TestRecorderDeviceAttachedLater(t *testing.T) {
+	vm := newTestMachine(t, "LOAD R1 16776960\nLOAD R2 65\nWRITE R2 R1\n")
+	r := NewRecorder(vm)
+	if err := r.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := vm.Attach(DefaultDeviceBase, NewConsoleOut(&out)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.Oldest() != 1 {
+		t.Errorf("история начинается с шага %d, ожидался шаг 1", r.Oldest())
+	}
+
+	if _, err := r.Back(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "A" {
+		t.Errorf("вывод %q, ожидалось %q", out.String(), "A")
+	}
+}
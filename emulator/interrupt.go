@@ -0,0 +1,239 @@
+package emulator
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Interrupter - устройство, которое может прервать выполнение программы. Машина
+// опрашивает такие устройства после каждой выполненной команды (после Tick всех
+// устройств), поэтому перед первой командой опроса нет; Interrupt может изменить PC.
+type Interrupter interface {
+	Interrupt(vm *VM) error
+}
+
+// Ticker - устройство, которому нужно время: Tick вызывается после каждой
+// выполненной команды
+type Ticker interface {
+	Tick()
+}
+
+// JumpRequest возвращается устройством при записи, чтобы следующей выполнялась
+// команда Target (например, возврат из обработчика прерывания)
+type JumpRequest struct {
+	Target uint32
+}
+
+func (e *JumpRequest) Error() string {
+	return fmt.Sprintf("переход к команде %d", e.Target)
+}
+
+// InterruptLines - число линий прерываний контроллера
+const InterruptLines = 32
+
+// Регистры контроллера прерываний
+const (
+	IntEnable  = iota // маска разрешенных линий
+	IntPending        // чтение - ожидающие линии, запись - сбросить указанные биты
+	IntVectors        // адрес таблицы векторов: обработчик линии i - слово Vectors + i
+	IntEPC            // PC прерванной команды, куда вернет IRET
+	IntIRET           // запись - возврат из обработчика
+	IntRaise          // запись номера линии - программное прерывание
+	intRegisters
+)
+
+// InterruptController - контроллер прерываний. Перед очередной командой, если есть
+// ожидающая разрешенная линия и обработчик не выполняется, контроллер сохраняет PC
+// в EPC и переходит к команде, адрес которой записан в таблице векторов. Линия
+// с меньшим номером имеет приоритет. Во время обработчика новые прерывания ждут
+// до записи в IRET. Линия остается ожидающей, пока обработчик не сбросит ее
+// записью в PENDING.
+type InterruptController struct {
+	enable  uint32
+	pending uint32
+	vectors uint32
+	epc     uint32
+	active  bool
+}
+
+// NewInterruptController создает контроллер с запрещенными прерываниями
+func NewInterruptController() *InterruptController {
+	return &InterruptController{}
+}
+
+func (c *InterruptController) Name() string      { return "intc" }
+func (c *InterruptController) Registers() uint32 { return intRegisters }
+
+// Raise делает линию ожидающей
+func (c *InterruptController) Raise(line uint32) {
+	if line < InterruptLines {
+		c.pending |= 1 << line
+	}
+}
+
+// Active сообщает, выполняется ли обработчик прерывания
+func (c *InterruptController) Active() bool {
+	return c.active
+}
+
+func (c *InterruptController) Read(reg uint32) (uint32, error) {
+	switch reg {
+	case IntEnable:
+		return c.enable, nil
+	case IntPending:
+		return c.pending, nil
+	case IntVectors:
+		return c.vectors, nil
+	case IntEPC:
+		return c.epc, nil
+	}
+	return 0, nil
+}
+
+func (c *InterruptController) Write(reg, value uint32) error {
+	switch reg {
+	case IntEnable:
+		c.enable = value
+	case IntPending:
+		c.pending &^= value
+	case IntVectors:
+		c.vectors = value
+	case IntEPC:
+		c.epc = value
+	case IntIRET:
+		if !c.active {
+			return fmt.Errorf("IRET вне обработчика прерывания")
+		}
+		c.active = false
+		return &JumpRequest{Target: c.epc}
+	case IntRaise:
+		if value >= InterruptLines {
+			return fmt.Errorf("нет линии прерывания %d", value)
+		}
+		c.Raise(value)
+	}
+	return nil
+}
+
+// SaveState возвращает регистры контроллера и признак выполнения обработчика
+func (c *InterruptController) SaveState() []uint32 {
+	return []uint32{c.enable, c.pending, c.vectors, c.epc, uint32(boolByte(c.active))}
+}
+
+func (c *InterruptController) RestoreState(state []uint32) error {
+	if err := checkState(c, state, 5); err != nil {
+		return err
+	}
+	c.enable, c.pending, c.vectors, c.epc = state[0], state[1], state[2], state[3]
+	c.active = state[4] != 0
+	return nil
+}
+
+// Interrupt передает управление обработчику ожидающей разрешенной линии
+func (c *InterruptController) Interrupt(vm *VM) error {
+	ready := c.pending & c.enable
+	if c.active || ready == 0 {
+		return nil
+	}
+
+	line := uint32(bits.TrailingZeros32(ready))
+	handler, err := vm.ReadMemory(c.vectors + line*vm.Model.WordCells())
+	if err != nil {
+		return fmt.Errorf("вектор прерывания %d: %w", line, err)
+	}
+	c.epc, c.active = vm.PC, true
+	vm.PC = handler
+	return nil
+}
+
+// Регистры таймера
+const (
+	TimerPeriod  = iota // период в командах
+	TimerCount          // команд до срабатывания; запись перезапускает отсчет
+	TimerControl        // биты TimerEnabled и TimerPeriodic
+	timerRegisters
+)
+
+// Биты регистра управления таймера
+const (
+	TimerEnabled  = 1 << 0
+	TimerPeriodic = 1 << 1 // после срабатывания снова отсчитывать период
+)
+
+// Timer - программируемый таймер, считающий выполненные команды. Когда счетчик
+// доходит до нуля, таймер поднимает свою линию прерывания и либо начинает новый
+// период, либо выключается.
+type Timer struct {
+	ic      *InterruptController
+	line    uint32
+	period  uint32
+	count   uint32
+	control uint32
+}
+
+// NewTimer создает выключенный таймер на линии line контроллера ic
+func NewTimer(ic *InterruptController, line uint32) *Timer {
+	return &Timer{ic: ic, line: line}
+}
+
+func (t *Timer) Name() string      { return "timer" }
+func (t *Timer) Registers() uint32 { return timerRegisters }
+
+func (t *Timer) Read(reg uint32) (uint32, error) {
+	switch reg {
+	case TimerPeriod:
+		return t.period, nil
+	case TimerCount:
+		return t.count, nil
+	case TimerControl:
+		return t.control, nil
+	}
+	return 0, nil
+}
+
+// Write настраивает таймер. Включение таймера без заданного счетчика
+// начинает отсчет с периода.
+func (t *Timer) Write(reg, value uint32) error {
+	switch reg {
+	case TimerPeriod:
+		t.period = value
+	case TimerCount:
+		t.count = value
+	case TimerControl:
+		if value&TimerEnabled != 0 && t.control&TimerEnabled == 0 && t.count == 0 {
+			t.count = t.period
+		}
+		t.control = value
+	}
+	return nil
+}
+
+// SaveState возвращает регистры таймера
+func (t *Timer) SaveState() []uint32 {
+	return []uint32{t.period, t.count, t.control}
+}
+
+func (t *Timer) RestoreState(state []uint32) error {
+	if err := checkState(t, state, 3); err != nil {
+		return err
+	}
+	t.period, t.count, t.control = state[0], state[1], state[2]
+	return nil
+}
+
+func (t *Timer) Tick() {
+	if t.control&TimerEnabled == 0 || t.count == 0 {
+		return
+	}
+	t.count--
+	if t.count > 0 {
+		return
+	}
+
+	t.ic.Raise(t.line)
+	if t.control&TimerPeriodic != 0 {
+		t.count = t.period
+	} else {
+		t.control &^= TimerEnabled
+	}
+}
@@ -0,0 +1,173 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LimitKind - вид ограничения выполнения
+type LimitKind int
+
+const (
+	LimitSteps  LimitKind = iota + 1 // число выполненных команд
+	LimitTime                        // время выполнения (срок контекста)
+	LimitMemory                      // размер памяти данных
+	LimitOutput                      // байтов, выведенных программой
+)
+
+var limitNames = map[LimitKind]string{
+	LimitSteps:  "лимит команд",
+	LimitTime:   "лимит времени",
+	LimitMemory: "лимит памяти",
+	LimitOutput: "лимит вывода",
+}
+
+func (k LimitKind) String() string {
+	if name, ok := limitNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("лимит %d", int(k))
+}
+
+// ExitCode возвращает код завершения процесса при превышении лимита.
+// Коды не пересекаются с кодами ошибок выполнения (FaultKind.ExitCode).
+func (k LimitKind) ExitCode() int {
+	return 20 + int(k)
+}
+
+// MarshalText позволяет записывать вид лимита в JSON строкой
+func (k LimitKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// checkInterval - через сколько команд проверяется контекст
+const checkInterval = 256
+
+// Limits - ограничения для выполнения недоверенных программ. Нулевое поле - без ограничения.
+type Limits struct {
+	Steps  uint64 // команд (на всех ядрах)
+	Memory int    // ячеек памяти данных
+	Output int64  // байтов вывода; применяется через LimitWriter
+}
+
+// LimitError - программа превысила ограничение. Машина остается в состоянии
+// на момент остановки: ее можно сохранить снимком или исследовать.
+type LimitError struct {
+	Kind  LimitKind `json:"kind"`
+	Limit uint64    `json:"limit"` // значение ограничения (для LimitTime - 0)
+	Steps uint64    `json:"steps"` // команд, выполненных до остановки
+}
+
+func (e *LimitError) Error() string {
+	switch e.Kind {
+	case LimitTime:
+		return fmt.Sprintf("превышен %s: выполнено %d команд", e.Kind, e.Steps)
+	case LimitMemory:
+		return fmt.Sprintf("превышен %s: память программы больше %d ячеек", e.Kind, e.Limit)
+	case LimitOutput:
+		return fmt.Sprintf("превышен %s: %d байт (выполнено %d команд)", e.Kind, e.Limit, e.Steps)
+	}
+	return fmt.Sprintf("превышен %s: %d команд", e.Kind, e.Limit)
+}
+
+// RunContext выполняет программу до завершения, ошибки, отмены ctx или превышения
+// лимита. Истечение срока ctx возвращается как *LimitError с видом LimitTime,
+// отмена - как ctx.Err().
+func (vm *VM) RunContext(ctx context.Context, limits Limits) error {
+	return runLimited(ctx, limits, len(vm.Memory), vm.Step, func() bool { return vm.Halted }, func() uint64 { return vm.Steps })
+}
+
+// RunContext выполняет программу на всех ядрах с ограничениями (см. VM.RunContext)
+func (m *Multicore) RunContext(ctx context.Context, limits Limits) error {
+	return runLimited(ctx, limits, len(m.Cores[0].Memory), m.Step, func() bool { return m.Halted }, func() uint64 { return m.Steps })
+}
+
+func runLimited(ctx context.Context, limits Limits, memory int, step func() error, halted func() bool, steps func() uint64) error {
+	l, err := newLimiter(ctx, limits, memory, steps())
+	if err != nil {
+		return err
+	}
+	for !halted() {
+		if err := l.check(steps()); err != nil {
+			return err
+		}
+		if err := step(); err != nil {
+			return l.stopped(err, steps())
+		}
+	}
+	return nil
+}
+
+// limiter проверяет ограничения перед каждой командой; контекст - раз в checkInterval команд
+type limiter struct {
+	ctx    context.Context
+	limits Limits
+	start  uint64
+	n      uint64
+}
+
+// newLimiter проверяет размер памяти и начинает отсчет команд с шага start
+func newLimiter(ctx context.Context, limits Limits, memory int, start uint64) (*limiter, error) {
+	if limits.Memory > 0 && memory > limits.Memory {
+		return nil, &LimitError{Kind: LimitMemory, Limit: uint64(limits.Memory)}
+	}
+	return &limiter{ctx: ctx, limits: limits, start: start}, nil
+}
+
+// check возвращает ошибку, если следующую команду выполнять нельзя
+func (l *limiter) check(steps uint64) error {
+	if l.n%checkInterval == 0 {
+		if err := l.ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return &LimitError{Kind: LimitTime, Steps: steps}
+			}
+			return err
+		}
+	}
+	l.n++
+	if l.limits.Steps > 0 && steps-l.start >= l.limits.Steps {
+		return &LimitError{Kind: LimitSteps, Limit: l.limits.Steps, Steps: steps}
+	}
+	return nil
+}
+
+// stopped дополняет ошибку команды числом выполненных команд, если это превышение лимита
+func (l *limiter) stopped(err error, steps uint64) error {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		limitErr.Steps = steps
+		return limitErr
+	}
+	return err
+}
+
+// LimitWriter пропускает в w не больше n байтов. Запись сверх лимита возвращает
+// *LimitError с видом LimitOutput; байты до лимита записываются.
+func LimitWriter(w io.Writer, n int64) io.Writer {
+	if n <= 0 {
+		return w
+	}
+	return &limitedWriter{w: w, left: n, limit: n}
+}
+
+type limitedWriter struct {
+	w     io.Writer
+	left  int64
+	limit int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= l.left {
+		l.left -= int64(len(p))
+		return l.w.Write(p)
+	}
+
+	n, err := l.w.Write(p[:l.left])
+	l.left = 0
+	if err != nil {
+		return n, err
+	}
+	return n, &LimitError{Kind: LimitOutput, Limit: uint64(l.limit)}
+}
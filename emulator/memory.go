@@ -0,0 +1,160 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Addressing - единица адресации памяти данных
+type Addressing string
+
+const (
+	WordAddressing Addressing = "word" // адрес - номер слова
+	ByteAddressing Addressing = "byte" // адрес - номер байта, слово занимает WordBits/8 байт
+)
+
+// BoundsPolicy - поведение при обращении за пределы памяти
+type BoundsPolicy string
+
+const (
+	TrapOutOfBounds BoundsPolicy = "trap" // ошибка выполнения
+	WrapOutOfBounds BoundsPolicy = "wrap" // адрес берется по модулю размера памяти
+)
+
+// MemoryModel - параметры памяти данных. Память состоит из ячеек: при адресации
+// по словам ячейка - слово шириной WordBits, при адресации по байтам - байт, а слово
+// собирается из WordBits/8 соседних ячеек в порядке little-endian.
+// Значения при записи обрезаются до WordBits, при чтении дополняются нулями.
+type MemoryModel struct {
+	Size        int          `json:"size"`      // ячеек
+	WordBits    uint         `json:"word_bits"` // 8, 16 или 32
+	Addressing  Addressing   `json:"addressing"`
+	OutOfBounds BoundsPolicy `json:"out_of_bounds"`
+}
+
+// DefaultMemoryModel - модель по умолчанию: 65536 32-битных слов, выход за границы - ошибка
+func DefaultMemoryModel() MemoryModel {
+	return MemoryModel{
+		Size:        DefaultMemorySize,
+		WordBits:    32,
+		Addressing:  WordAddressing,
+		OutOfBounds: TrapOutOfBounds,
+	}
+}
+
+// LoadMemoryModel читает модель памяти из файла JSON; незаданные поля
+// получают значения по умолчанию
+func LoadMemoryModel(path string) (MemoryModel, error) {
+	model := DefaultMemoryModel()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return model, err
+	}
+	if err := json.Unmarshal(data, &model); err != nil {
+		return model, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := model.Validate(); err != nil {
+		return model, fmt.Errorf("%s: %v", path, err)
+	}
+	return model, nil
+}
+
+// Validate проверяет параметры модели, заполняя незаданные значениями по умолчанию
+func (m *MemoryModel) Validate() error {
+	def := DefaultMemoryModel()
+	if m.Size == 0 {
+		m.Size = def.Size
+	}
+	if m.WordBits == 0 {
+		m.WordBits = def.WordBits
+	}
+	if m.Addressing == "" {
+		m.Addressing = def.Addressing
+	}
+	if m.OutOfBounds == "" {
+		m.OutOfBounds = def.OutOfBounds
+	}
+
+	switch {
+	case m.Size < 0:
+		return fmt.Errorf("неверный размер памяти: %d", m.Size)
+	case m.WordBits != 8 && m.WordBits != 16 && m.WordBits != 32:
+		return fmt.Errorf("ширина слова должна быть 8, 16 или 32 бита: %d", m.WordBits)
+	case m.Addressing != WordAddressing && m.Addressing != ByteAddressing:
+		return fmt.Errorf("неизвестная адресация: %s (допустимо: word, byte)", m.Addressing)
+	case m.OutOfBounds != TrapOutOfBounds && m.OutOfBounds != WrapOutOfBounds:
+		return fmt.Errorf("неизвестное поведение за границами памяти: %s (допустимо: trap, wrap)", m.OutOfBounds)
+	case m.Addressing == ByteAddressing && m.Size%int(m.WordBits/8) != 0:
+		return fmt.Errorf("размер памяти %d байт не кратен размеру слова (%d байт)", m.Size, m.WordBits/8)
+	}
+	return nil
+}
+
+func (m MemoryModel) String() string {
+	unit := "слов"
+	if m.Addressing == ByteAddressing {
+		unit = "байт"
+	}
+	bounds := "ошибка"
+	if m.OutOfBounds == WrapOutOfBounds {
+		bounds = "адрес по модулю размера"
+	}
+	return fmt.Sprintf("%d %s, слово %d бит, за границами памяти - %s", m.Size, unit, m.WordBits, bounds)
+}
+
+// WordCells возвращает, сколько ячеек занимает одно слово
+func (m MemoryModel) WordCells() uint32 {
+	if m.Addressing == ByteAddressing {
+		return uint32(m.WordBits / 8)
+	}
+	return 1
+}
+
+// mask возвращает маску значимых битов слова
+func (m MemoryModel) mask() uint32 {
+	return uint32(uint64(1)<<m.WordBits - 1)
+}
+
+// locate возвращает номер ячейки, с которой начинается слово по адресу addr,
+// применяя поведение модели при выходе за границы памяти
+func (vm *VM) locate(addr uint32, kind AccessKind) (uint32, error) {
+	size := uint64(len(vm.Memory))
+	if vm.Model.OutOfBounds == WrapOutOfBounds && size > 0 {
+		return uint32(uint64(addr) % size), nil
+	}
+	if uint64(addr)+uint64(vm.Model.WordCells()) > size {
+		return 0, &MemoryError{Kind: kind, Addr: addr, Size: int(size)}
+	}
+	return addr, nil
+}
+
+// peek читает слово, начинающееся с ячейки start
+func (vm *VM) peek(start uint32) uint32 {
+	cells := vm.Model.WordCells()
+	if cells == 1 {
+		return vm.Memory[start] & vm.Model.mask()
+	}
+
+	size := uint64(len(vm.Memory))
+	var value uint32
+	for i := int(cells) - 1; i >= 0; i-- {
+		value = value<<8 | vm.Memory[(uint64(start)+uint64(i))%size]&0xFF
+	}
+	return value
+}
+
+// poke записывает слово, начиная с ячейки start
+func (vm *VM) poke(start, value uint32) {
+	value &= vm.Model.mask()
+	cells := vm.Model.WordCells()
+	if cells == 1 {
+		vm.Memory[start] = value
+		return
+	}
+
+	size := uint64(len(vm.Memory))
+	for i := uint32(0); i < cells; i++ {
+		vm.Memory[(uint64(start)+uint64(i))%size] = value >> (8 * i) & 0xFF
+	}
+}
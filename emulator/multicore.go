@@ -0,0 +1,403 @@
+package emulator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Schedule - порядок, в котором многоядерная машина выполняет команды ядер
+type Schedule string
+
+const (
+	ScheduleRoundRobin Schedule = "round-robin" // по очереди, по Quantum команд
+	ScheduleRandom     Schedule = "random"      // каждая команда - на случайном ядре (Seed)
+)
+
+// Schedules - допустимые значения Schedule
+var Schedules = []string{string(ScheduleRoundRobin), string(ScheduleRandom)}
+
+// Регистры устройства синхронизации ядер
+const (
+	SyncCore    = iota // чтение - номер ядра
+	SyncCores          // чтение - число ядер
+	SyncBarrier        // запись - ждать, пока все работающие ядра не дойдут до барьера
+	SyncLock           // запись - захватить блокировку (ждать, если занята); чтение - владелец + 1 или 0
+	SyncUnlock         // запись - освободить блокировку
+	syncRegisters
+)
+
+// SyncDeviceOffset - смещение устройства синхронизации в словах от адреса
+// стандартных устройств: сразу за таймером
+const SyncDeviceOffset = 3 + intRegisters + timerRegisters
+
+// MulticoreOptions - параметры многоядерной машины
+type MulticoreOptions struct {
+	Cores    int
+	Schedule Schedule
+	Seed     int64 // для ScheduleRandom
+	Quantum  int   // команд подряд на ядре для ScheduleRoundRobin (0 - 1)
+	SyncBase uint32
+}
+
+// coreState - может ли ядро выполнять команды
+type coreState int
+
+const (
+	coreRunning coreState = iota
+	coreAtBarrier
+	coreOnLock
+)
+
+// Multicore - машина из нескольких ядер с общей памятью данных. У каждого ядра
+// свои регистры и PC, все ядра начинают с точки входа программы. Ядро узнает свой
+// номер из устройства синхронизации, которое также дает барьер и блокировку.
+//
+// Гонкой считаются два обращения разных ядер к одной ячейке, хотя бы одно из которых -
+// запись, если между ними нет точки синхронизации: барьера или освобождения
+// и последующего захвата блокировки. Порядок обращений отслеживается векторными часами.
+type Multicore struct {
+	Cores  []*VM
+	Steps  uint64 // команд на всех ядрах
+	Last   int    // ядро, выполнившее последнюю команду
+	Halted bool
+	Races  []Race
+
+	opts    MulticoreOptions
+	rng     *rand.Rand
+	state   []coreState
+	current int
+	slice   int // команд текущего ядра подряд
+
+	owner int   // владелец блокировки или -1
+	queue []int // ядра, ждущие блокировку
+
+	clocks    [][]uint64 // векторные часы ядер
+	lockClock []uint64   // часы последнего освобождения блокировки
+	shadow    map[uint32]*shadowCell
+	reported  map[raceKey]bool
+}
+
+// Race - гонка данных: два неупорядоченных обращения к ячейке Addr
+type Race struct {
+	Addr          uint32     `json:"addr"`
+	First, Second RaceAccess `json:"-"`
+}
+
+// RaceAccess - одно из обращений, участвующих в гонке
+type RaceAccess struct {
+	Core int        `json:"core"`
+	PC   uint32     `json:"pc"`
+	Step uint64     `json:"step"` // номер команды машины (Multicore.Steps)
+	Kind AccessKind `json:"kind"`
+}
+
+// shadowCell - последние обращения к ячейке: запись и чтение каждого ядра
+type shadowCell struct {
+	write     shadowAccess
+	writeCore int
+	reads     []shadowAccess
+}
+
+// shadowAccess - обращение с временем ядра по его часам; clock 0 - обращения не было
+type shadowAccess struct {
+	clock uint64
+	pc    uint32
+	step  uint64
+}
+
+type raceKey struct {
+	addr     uint32
+	firstPC  uint32
+	secondPC uint32
+}
+
+// NewMulticore создает многоядерную машину. Ядро 0 - vm, остальные ядра - его копии
+// с нулевыми регистрами и PC точки входа; память, устройства, трассировщик и профиль
+// у всех ядер общие. Каждому ядру подключается свое устройство синхронизации по адресу
+// SyncBase.
+func NewMulticore(vm *VM, opts MulticoreOptions) (*Multicore, error) {
+	if opts.Cores < 1 {
+		return nil, fmt.Errorf("число ядер должно быть положительным: %d", opts.Cores)
+	}
+	if opts.Quantum <= 0 {
+		opts.Quantum = 1
+	}
+	if opts.Schedule == "" {
+		opts.Schedule = ScheduleRoundRobin
+	}
+	if opts.Schedule != ScheduleRoundRobin && opts.Schedule != ScheduleRandom {
+		return nil, fmt.Errorf("неизвестный порядок выполнения: %s (допустимо: %s)", opts.Schedule, strings.Join(Schedules, ", "))
+	}
+
+	m := &Multicore{
+		opts:      opts,
+		rng:       rand.New(rand.NewSource(opts.Seed)),
+		state:     make([]coreState, opts.Cores),
+		owner:     -1,
+		clocks:    make([][]uint64, opts.Cores),
+		lockClock: make([]uint64, opts.Cores),
+		shadow:    make(map[uint32]*shadowCell),
+		reported:  make(map[raceKey]bool),
+	}
+
+	shared := append([]DeviceMapping(nil), vm.Devices...)
+	for i := 0; i < opts.Cores; i++ {
+		core := vm
+		if i > 0 {
+			clone := *vm
+			clone.Regs = [RegisterCount]uint32{}
+			clone.Accesses = nil
+			clone.Devices = append([]DeviceMapping(nil), shared...)
+			core = &clone
+		}
+		if err := core.Attach(opts.SyncBase, &syncDevice{m: m, core: i}); err != nil {
+			return nil, err
+		}
+		m.Cores = append(m.Cores, core)
+		m.clocks[i] = make([]uint64, opts.Cores)
+		m.clocks[i][i] = 1
+	}
+
+	m.Halted = vm.Halted
+	return m, nil
+}
+
+// Exited сообщает, завершилась ли программа через порт останова, и код завершения
+func (m *Multicore) Exited() (bool, uint32) {
+	for _, core := range m.Cores {
+		if core.Exited {
+			return true, core.ExitCode
+		}
+	}
+	return false, 0
+}
+
+// Step выполняет одну команду на ядре, выбранном планировщиком
+func (m *Multicore) Step() error {
+	if m.Halted {
+		return ErrHalted
+	}
+
+	c, err := m.next()
+	if err != nil {
+		return err
+	}
+	core := m.Cores[c]
+	pc := core.PC
+	m.Last = c
+	if err := core.Step(); err != nil {
+		return fmt.Errorf("ядро %d: %w", c, err)
+	}
+	m.Steps++
+	m.slice++
+
+	for _, a := range core.Accesses {
+		if a.Device == "" {
+			m.check(c, pc, a)
+		}
+	}
+
+	if exited, _ := m.Exited(); exited {
+		m.Halted = true
+		return nil
+	}
+	m.releaseBarrier()
+	m.Halted = true
+	for _, core := range m.Cores {
+		if !core.Halted {
+			m.Halted = false
+		}
+	}
+	return nil
+}
+
+// Run выполняет программу на всех ядрах до завершения или ошибки
+func (m *Multicore) Run() error {
+	for !m.Halted {
+		if err := m.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runnable сообщает, может ли ядро выполнить команду
+func (m *Multicore) runnable(c int) bool {
+	return !m.Cores[c].Halted && m.state[c] == coreRunning
+}
+
+// next выбирает ядро для следующей команды
+func (m *Multicore) next() (int, error) {
+	var ready []int
+	for c := range m.Cores {
+		if m.runnable(c) {
+			ready = append(ready, c)
+		}
+	}
+	if len(ready) == 0 {
+		return 0, fmt.Errorf("взаимная блокировка: все работающие ядра ждут барьер или блокировку")
+	}
+
+	if m.opts.Schedule == ScheduleRandom {
+		m.current = ready[m.rng.Intn(len(ready))]
+		return m.current, nil
+	}
+	if m.runnable(m.current) && m.slice < m.opts.Quantum {
+		return m.current, nil
+	}
+	m.slice = 0
+	for i := 1; i <= len(m.Cores); i++ {
+		if c := (m.current + i) % len(m.Cores); m.runnable(c) {
+			m.current = c
+			break
+		}
+	}
+	return m.current, nil
+}
+
+// releaseBarrier отпускает ядра барьера, когда до него дошли все работающие ядра.
+// После барьера каждое ядро видит все обращения, сделанные до него.
+func (m *Multicore) releaseBarrier() {
+	var waiting []int
+	for c, core := range m.Cores {
+		switch {
+		case core.Halted:
+		case m.state[c] != coreAtBarrier:
+			return
+		default:
+			waiting = append(waiting, c)
+		}
+	}
+
+	joined := make([]uint64, len(m.Cores))
+	for _, c := range waiting {
+		join(joined, m.clocks[c])
+	}
+	for _, c := range waiting {
+		copy(m.clocks[c], joined)
+		m.clocks[c][c]++
+		m.state[c] = coreRunning
+	}
+}
+
+// lock захватывает блокировку для ядра c или ставит его в очередь
+func (m *Multicore) lock(c int) error {
+	switch {
+	case m.owner == c:
+		return fmt.Errorf("ядро %d уже владеет блокировкой", c)
+	case m.owner >= 0:
+		m.state[c] = coreOnLock
+		m.queue = append(m.queue, c)
+	default:
+		m.acquire(c)
+	}
+	return nil
+}
+
+// unlock освобождает блокировку и передает ее первому ядру очереди
+func (m *Multicore) unlock(c int) error {
+	if m.owner != c {
+		return fmt.Errorf("ядро %d не владеет блокировкой", c)
+	}
+	copy(m.lockClock, m.clocks[c])
+	m.clocks[c][c]++
+	m.owner = -1
+
+	if len(m.queue) > 0 {
+		next := m.queue[0]
+		m.queue = m.queue[1:]
+		m.state[next] = coreRunning
+		m.acquire(next)
+	}
+	return nil
+}
+
+func (m *Multicore) acquire(c int) {
+	m.owner = c
+	join(m.clocks[c], m.lockClock)
+}
+
+// check сравнивает обращение ядра c с предыдущими обращениями других ядер
+// к той же ячейке и запоминает его
+func (m *Multicore) check(c int, pc uint32, a Access) {
+	cell := m.shadow[a.Addr]
+	if cell == nil {
+		cell = &shadowCell{reads: make([]shadowAccess, len(m.Cores))}
+		m.shadow[a.Addr] = cell
+	}
+	clock := m.clocks[c]
+	now := shadowAccess{clock: clock[c], pc: pc, step: m.Steps}
+
+	// Последняя запись - чужая и не упорядочена с этим обращением
+	if w, owner := cell.write, cell.writeCore; w.clock > 0 && owner != c && w.clock > clock[owner] {
+		m.report(a.Addr, RaceAccess{Core: owner, PC: w.pc, Step: w.step, Kind: AccessWrite}, c, now, a.Kind)
+	}
+
+	if a.Kind == AccessRead {
+		cell.reads[c] = now
+		return
+	}
+	for other, r := range cell.reads {
+		if other != c && r.clock > 0 && r.clock > clock[other] {
+			m.report(a.Addr, RaceAccess{Core: other, PC: r.pc, Step: r.step, Kind: AccessRead}, c, now, a.Kind)
+		}
+		cell.reads[other] = shadowAccess{}
+	}
+	cell.write = now
+	cell.writeCore = c
+}
+
+// report запоминает гонку; одна и та же пара команд для ячейки сообщается один раз
+func (m *Multicore) report(addr uint32, first RaceAccess, c int, now shadowAccess, kind AccessKind) {
+	key := raceKey{addr: addr, firstPC: first.PC, secondPC: now.pc}
+	if m.reported[key] {
+		return
+	}
+	m.reported[key] = true
+	m.Races = append(m.Races, Race{
+		Addr:   addr,
+		First:  first,
+		Second: RaceAccess{Core: c, PC: now.pc, Step: now.step, Kind: kind},
+	})
+}
+
+// join записывает в dst поэлементный максимум dst и src
+func join(dst, src []uint64) {
+	for i := range dst {
+		dst[i] = max(dst[i], src[i])
+	}
+}
+
+// syncDevice - устройство синхронизации ядра core (см. регистры Sync*)
+type syncDevice struct {
+	m    *Multicore
+	core int
+}
+
+func (d *syncDevice) Name() string      { return "sync" }
+func (d *syncDevice) Registers() uint32 { return syncRegisters }
+
+func (d *syncDevice) Read(reg uint32) (uint32, error) {
+	switch reg {
+	case SyncCore:
+		return uint32(d.core), nil
+	case SyncCores:
+		return uint32(len(d.m.Cores)), nil
+	case SyncLock:
+		return uint32(d.m.owner + 1), nil
+	}
+	return 0, nil
+}
+
+func (d *syncDevice) Write(reg, value uint32) error {
+	switch reg {
+	case SyncBarrier:
+		d.m.state[d.core] = coreAtBarrier
+	case SyncLock:
+		return d.m.lock(d.core)
+	case SyncUnlock:
+		return d.m.unlock(d.core)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package emulator
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+	"time"
+	"uvm-assembler/assembler"
+)
+
+// Профиль pprof - сообщение profile.proto (github.com/google/pprof/proto/profile.proto),
+// сжатое gzip. Сообщение кодируется вручную, чтобы не зависеть от protobuf.
+// Каждая выполненная команда - отдельный образец с тремя значениями: выполнения,
+// чтения и записи памяти. Адрес точки (location) - номер команды, функция - ближайшая
+// метка кода (или имя исходного файла), строка - строка исходного текста из .dbg.
+//
+//	go tool pprof -top -lines program.pb.gz
+//	go tool pprof -list . program.pb.gz
+
+// Номера полей profile.proto
+const (
+	pprofSampleType   = 1
+	pprofSample       = 2
+	pprofMapping      = 3
+	pprofLocation     = 4
+	pprofFunction     = 5
+	pprofStringTable  = 6
+	pprofTimeNanos    = 9
+	pprofDuration     = 10
+	pprofPeriodType   = 11
+	pprofPeriod       = 12
+	pprofDefaultType  = 14
+	pprofValueType    = 1 // ValueType.type
+	pprofValueUnit    = 2 // ValueType.unit
+	pprofSampleLocs   = 1 // Sample.location_id
+	pprofSampleValues = 2 // Sample.value
+)
+
+// protoBuffer - минимальный кодировщик protobuf: varint и вложенные сообщения
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) key(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint64Field записывает поле varint; нулевые значения, как принято в proto3, пропускаются
+func (b *protoBuffer) uint64Field(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int64Field(field int, x int64) {
+	b.uint64Field(field, uint64(x))
+}
+
+func (b *protoBuffer) boolField(field int, x bool) {
+	if x {
+		b.uint64Field(field, 1)
+	}
+}
+
+func (b *protoBuffer) bytesField(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// packed записывает повторяющееся поле varint в упакованном виде
+func (b *protoBuffer) packed(field int, values []uint64) {
+	var inner protoBuffer
+	for _, x := range values {
+		inner.varint(x)
+	}
+	b.bytesField(field, inner.data)
+}
+
+func (b *protoBuffer) message(field int, encode func(m *protoBuffer)) {
+	var inner protoBuffer
+	encode(&inner)
+	b.bytesField(field, inner.data)
+}
+
+// stringTable - таблица строк профиля; строка 0 всегда пустая
+type stringTable struct {
+	strings []string
+	index   map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{strings: []string{""}, index: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) id(s string) int64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	t.index[s] = int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	return t.index[s]
+}
+
+// WritePprof записывает профиль в формате pprof. program - имя программы для
+// отображения (mapping), duration - время выполнения (0 - не указывать).
+func (p *Profile) WritePprof(w io.Writer, vm *VM, symbols *assembler.SymbolTable, program string, duration time.Duration) error {
+	strs := newStringTable()
+	var b protoBuffer
+
+	for _, kind := range []string{"instructions", "reads", "writes"} {
+		b.message(pprofSampleType, func(m *protoBuffer) {
+			m.int64Field(pprofValueType, strs.id(kind))
+			m.int64Field(pprofValueUnit, strs.id("count"))
+		})
+	}
+
+	// Функции - по имени метки и файлу
+	type functionKey struct{ name, file string }
+	functions := make(map[functionKey]uint64)
+	var functionOrder []functionKey
+
+	spots := p.HotSpots()
+	for _, addr := range spots {
+		id := uint64(addr) + 1
+		b.message(pprofSample, func(m *protoBuffer) {
+			m.packed(pprofSampleLocs, []uint64{id})
+			m.packed(pprofSampleValues, []uint64{p.Exec[addr], p.Reads[addr], p.Writes[addr]})
+		})
+	}
+
+	b.message(pprofMapping, func(m *protoBuffer) {
+		m.uint64Field(1, 1)                     // id
+		m.uint64Field(3, uint64(len(p.Exec))+1) // memory_limit
+		m.int64Field(5, strs.id(program))       // filename
+		m.boolField(7, true)                    // has_functions
+		m.boolField(8, vm.Debug != nil)         // has_filenames
+		m.boolField(9, vm.Debug != nil)         // has_line_numbers
+	})
+
+	for _, addr := range spots {
+		key := functionKey{name: codeLabel(symbols, addr)}
+		var line int64
+		if entry, ok := vm.Debug.Lookup(addr); ok {
+			key.file = entry.File
+			line = int64(entry.Line)
+		}
+		if key.name == "" {
+			key.name = key.file
+		}
+		if key.name == "" {
+			key.name = "program"
+		}
+		if _, ok := functions[key]; !ok {
+			functions[key] = uint64(len(functions)) + 1
+			functionOrder = append(functionOrder, key)
+		}
+
+		b.message(pprofLocation, func(m *protoBuffer) {
+			m.uint64Field(1, uint64(addr)+1) // id
+			m.uint64Field(2, 1)              // mapping_id
+			m.uint64Field(3, uint64(addr))   // address
+			m.message(4, func(l *protoBuffer) {
+				l.uint64Field(1, functions[key]) // function_id
+				l.int64Field(2, line)
+			})
+		})
+	}
+
+	for _, key := range functionOrder {
+		b.message(pprofFunction, func(m *protoBuffer) {
+			m.uint64Field(1, functions[key])
+			m.int64Field(2, strs.id(key.name))
+			m.int64Field(3, strs.id(key.name))
+			m.int64Field(4, strs.id(key.file))
+		})
+	}
+
+	if duration > 0 {
+		b.int64Field(pprofTimeNanos, time.Now().Add(-duration).UnixNano())
+		b.int64Field(pprofDuration, duration.Nanoseconds())
+	}
+	b.message(pprofPeriodType, func(m *protoBuffer) {
+		m.int64Field(pprofValueType, strs.id("instructions"))
+		m.int64Field(pprofValueUnit, strs.id("count"))
+	})
+	b.int64Field(pprofPeriod, 1)
+	b.int64Field(pprofDefaultType, strs.id("instructions"))
+
+	// Таблица строк пополняется при кодировании, поэтому записывается последней
+	for _, s := range strs.strings {
+		b.bytesField(pprofStringTable, []byte(s))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// codeLabel возвращает имя метки кода, к которой относится команда
+func codeLabel(symbols *assembler.SymbolTable, addr uint32) string {
+	name, _, _ := strings.Cut(symbols.Describe(assembler.SectionCode, addr), "+")
+	return name
+}
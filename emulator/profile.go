@@ -0,0 +1,197 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"uvm-assembler/assembler"
+)
+
+// Profile - счетчики выполнения программы: сколько раз выполнена каждая команда
+// и каждый код операции, сколько обращений к памяти сделала каждая команда
+// и сколько раз прочитана и записана каждая ячейка памяти
+type Profile struct {
+	Steps   uint64
+	Exec    []uint64 // по адресу команды
+	Reads   []uint64 // чтения памяти, выполненные командой по адресу
+	Writes  []uint64 // записи памяти, выполненные командой по адресу
+	Opcodes map[assembler.CommandType]uint64
+
+	// Обращения по фактическому адресу ячейки памяти (см. MemoryModel),
+	// без обращений к регистрам устройств
+	MemReads  map[uint32]uint64
+	MemWrites map[uint32]uint64
+}
+
+// NewProfile создает пустой профиль для программы из codeLen команд
+func NewProfile(codeLen uint32) *Profile {
+	return &Profile{
+		Exec:      make([]uint64, codeLen),
+		Reads:     make([]uint64, codeLen),
+		Writes:    make([]uint64, codeLen),
+		Opcodes:   make(map[assembler.CommandType]uint64),
+		MemReads:  make(map[uint32]uint64),
+		MemWrites: make(map[uint32]uint64),
+	}
+}
+
+// record учитывает выполненную команду и ее обращения к памяти
+func (p *Profile) record(pc uint32, op assembler.CommandType, accesses []Access) {
+	if int(pc) >= len(p.Exec) {
+		n := int(pc) + 1
+		p.Exec = append(p.Exec, make([]uint64, n-len(p.Exec))...)
+		p.Reads = append(p.Reads, make([]uint64, n-len(p.Reads))...)
+		p.Writes = append(p.Writes, make([]uint64, n-len(p.Writes))...)
+	}
+
+	p.Steps++
+	p.Exec[pc]++
+	p.Opcodes[op]++
+	for _, a := range accesses {
+		if a.Kind == AccessWrite {
+			p.Writes[pc]++
+		} else {
+			p.Reads[pc]++
+		}
+		switch {
+		case a.Device != "":
+			// Регистры устройств - не ячейки памяти
+		case a.Kind == AccessWrite:
+			p.MemWrites[a.Addr]++
+		default:
+			p.MemReads[a.Addr]++
+		}
+	}
+}
+
+// HotSpots возвращает адреса выполненных команд по убыванию числа выполнений
+func (p *Profile) HotSpots() []uint32 {
+	var addrs []uint32
+	for addr, n := range p.Exec {
+		if n > 0 {
+			addrs = append(addrs, uint32(addr))
+		}
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return p.Exec[addrs[i]] > p.Exec[addrs[j]] })
+	return addrs
+}
+
+// WriteReport выводит отчет о горячих точках: распределение по кодам операций,
+// top самых часто выполняемых команд с местом и текстом исходной строки
+// и top самых часто используемых ячеек памяти. top <= 0 - без ограничения.
+func (p *Profile) WriteReport(w io.Writer, vm *VM, symbols *assembler.SymbolTable, top int) {
+	fmt.Fprintf(w, "📊 Профиль: выполнено %d команд\n", p.Steps)
+	if p.Steps == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nКоды операций:")
+	ops := make([]assembler.CommandType, 0, len(p.Opcodes))
+	for op := range p.Opcodes {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if p.Opcodes[ops[i]] != p.Opcodes[ops[j]] {
+			return p.Opcodes[ops[i]] > p.Opcodes[ops[j]]
+		}
+		return ops[i] < ops[j]
+	})
+	for _, op := range ops {
+		fmt.Fprintf(w, "  %-6s %12d %6.2f%%\n", op.TypeName(), p.Opcodes[op], p.percent(p.Opcodes[op]))
+	}
+
+	spots := p.HotSpots()
+	fmt.Fprintf(w, "\nГорячие точки (%d из %d выполненных команд):\n", limit(len(spots), top), len(spots))
+	fmt.Fprintf(w, "  %12s %7s %10s %10s %6s  %-20s %s\n", "выполнений", "%", "чтений", "записей", "адрес", "команда", "источник")
+	sources := newSourceCache()
+	for _, addr := range spots[:limit(len(spots), top)] {
+		instruction := "?"
+		if cmd, err := vm.Fetch(addr); err == nil {
+			instruction = assembler.Disassemble(cmd)
+		}
+		location := symbols.Describe(assembler.SectionCode, addr)
+		if entry, ok := vm.Debug.Lookup(addr); ok {
+			location = entry.SourceLocation.String()
+			if text := sources.line(entry.File, entry.Line); text != "" {
+				location += "  " + text
+			}
+		}
+		fmt.Fprintf(w, "  %12d %6.2f%% %10d %10d %6d  %-20s %s\n",
+			p.Exec[addr], p.percent(p.Exec[addr]), p.Reads[addr], p.Writes[addr], addr, instruction, location)
+	}
+
+	cells := p.MemoryHotSpots()
+	if len(cells) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nПамять (%d из %d использованных ячеек):\n", limit(len(cells), top), len(cells))
+	fmt.Fprintf(w, "  %10s %10s %10s  %s\n", "адрес", "чтений", "записей", "символ")
+	for _, addr := range cells[:limit(len(cells), top)] {
+		fmt.Fprintf(w, "  %10d %10d %10d  %s\n", addr, p.MemReads[addr], p.MemWrites[addr], symbols.Describe(assembler.SectionData, addr))
+	}
+}
+
+// MemoryHotSpots возвращает адреса ячеек памяти по убыванию числа обращений
+func (p *Profile) MemoryHotSpots() []uint32 {
+	seen := make(map[uint32]bool)
+	var addrs []uint32
+	for _, counts := range []map[uint32]uint64{p.MemReads, p.MemWrites} {
+		for addr := range counts {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		a := p.MemReads[addrs[i]] + p.MemWrites[addrs[i]]
+		b := p.MemReads[addrs[j]] + p.MemWrites[addrs[j]]
+		if a != b {
+			return a > b
+		}
+		return addrs[i] < addrs[j]
+	})
+	return addrs
+}
+
+func (p *Profile) percent(n uint64) float64 {
+	return float64(n) * 100 / float64(p.Steps)
+}
+
+func limit(n, top int) int {
+	if top > 0 && top < n {
+		return top
+	}
+	return n
+}
+
+// sourceCache читает исходные тексты для отчетов. Недоступный файл
+// запоминается как пустой, чтобы не пытаться читать его снова.
+type sourceCache map[string][]string
+
+func newSourceCache() sourceCache {
+	return make(sourceCache)
+}
+
+// line возвращает текст строки файла без отступов или пустую строку
+func (c sourceCache) line(file string, line int) string {
+	lines, ok := c[file]
+	if !ok {
+		if f, err := os.Open(filepath.Clean(file)); err == nil {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			f.Close()
+		}
+		c[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line-1])
+}
@@ -0,0 +1,241 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+// Снимок состояния машины (little-endian):
+//
+//	magic "UVMS", version uint16
+//	steps   uint64   количество выполненных команд
+//	pc      uint32
+//	halted  uint8
+//	model   uint8 ширина слова в битах, uint8 адресация по байтам, uint8 выход за границы по модулю
+//	regs    64 × uint32
+//	memory  uint32 размер в ячейках, uint32 количество участков,
+//	        участки {addr uint32, count uint32, count × uint32} - ненулевые ячейки
+//	devices uint32 количество + {base uint32, uint8 длина + имя, uint32 n, n × uint32} -
+//	        состояния устройств StatefulDevice
+//	code    uint32 длина + байты загруженной программы
+//	crc32   CRC32 (IEEE) всего предшествующего содержимого
+//
+// Одно и то же состояние всегда дает одинаковые байты снимка.
+// Снимки версии 1 не содержат модели памяти и загружаются с моделью по умолчанию,
+// снимки версий 1 и 2 не содержат состояний устройств.
+
+const SnapshotVersion = 3
+
+// MaxSnapshotMemory - наибольший размер памяти в снимке: 2^24 ячеек (64 МиБ).
+// Память в снимке хранится ненулевыми участками, поэтому короткий поврежденный
+// снимок может объявить любой размер; ограничение не дает ему выделить гигабайты.
+const MaxSnapshotMemory = 1 << 24
+
+// maxDeviceState ограничивает число значений в состоянии одного устройства
+const maxDeviceState = 256
+
+var snapshotMagic = []byte("UVMS")
+
+// Snapshot сериализует состояние машины: PC, регистры, память, состояния устройств и программу
+func (vm *VM) Snapshot() []byte {
+	var buf bytes.Buffer
+	put := func(v any) {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+
+	buf.Write(snapshotMagic)
+	put(uint16(SnapshotVersion))
+	put(vm.Steps)
+	put(vm.PC)
+	put(boolByte(vm.Halted))
+	put(uint8(vm.Model.WordBits))
+	put(boolByte(vm.Model.Addressing == ByteAddressing))
+	put(boolByte(vm.Model.OutOfBounds == WrapOutOfBounds))
+	put(vm.Regs)
+
+	runs := nonZeroRuns(vm.Memory)
+	put(uint32(len(vm.Memory)))
+	put(uint32(len(runs)))
+	for _, r := range runs {
+		put(uint32(r[0]))
+		put(uint32(r[1] - r[0]))
+		put(vm.Memory[r[0]:r[1]])
+	}
+
+	devices := vm.SaveDevices()
+	put(uint32(len(devices)))
+	for _, d := range devices {
+		put(d.Base)
+		put(uint8(len(d.Name)))
+		buf.WriteString(d.Name[:uint8(len(d.Name))])
+		put(uint32(len(d.State)))
+		put(d.State)
+	}
+
+	put(uint32(len(vm.Code)))
+	buf.Write(vm.Code)
+
+	put(crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// RestoreSnapshot создает машину из снимка. Состояния устройств возвращаются им
+// при подключении к машине (см. VM.PendingDevices).
+func RestoreSnapshot(data []byte) (*VM, error) {
+	if !bytes.HasPrefix(data, snapshotMagic) {
+		return nil, fmt.Errorf("не является снимком состояния УВМ")
+	}
+	if len(data) < len(snapshotMagic)+6 {
+		return nil, fmt.Errorf("снимок обрезан")
+	}
+
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, fmt.Errorf("неверная контрольная сумма снимка")
+	}
+
+	r := bytes.NewReader(body[len(snapshotMagic):])
+	var err error
+	get := func(v any) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, v)
+		}
+	}
+
+	var version uint16
+	get(&version)
+	if err == nil && (version < 1 || version > SnapshotVersion) {
+		return nil, fmt.Errorf("неподдерживаемая версия снимка: %d", version)
+	}
+
+	vm := &VM{Model: DefaultMemoryModel()}
+	var halted, wordBits, byteAddressed, wrap uint8
+	var memSize, runCount, codeLen uint32
+	get(&vm.Steps)
+	get(&vm.PC)
+	get(&halted)
+	if version >= 2 {
+		get(&wordBits)
+		get(&byteAddressed)
+		get(&wrap)
+		vm.Model.WordBits = uint(wordBits)
+		if byteAddressed != 0 {
+			vm.Model.Addressing = ByteAddressing
+		}
+		if wrap != 0 {
+			vm.Model.OutOfBounds = WrapOutOfBounds
+		}
+	}
+	get(&vm.Regs)
+	get(&memSize)
+	get(&runCount)
+	if err != nil {
+		return nil, fmt.Errorf("снимок поврежден: %v", err)
+	}
+	if memSize > MaxSnapshotMemory {
+		return nil, fmt.Errorf("снимок поврежден: размер памяти %d ячеек больше %d", memSize, MaxSnapshotMemory)
+	}
+	if uint64(runCount)*8 > uint64(r.Len()) {
+		return nil, fmt.Errorf("снимок поврежден: %d участков памяти", runCount)
+	}
+
+	vm.Model.Size = int(memSize)
+	if err := vm.Model.Validate(); err != nil {
+		return nil, fmt.Errorf("снимок поврежден: %v", err)
+	}
+
+	vm.Halted = halted != 0
+	vm.Memory = make([]uint32, memSize)
+	for i := uint32(0); i < runCount && err == nil; i++ {
+		var addr, count uint32
+		get(&addr)
+		get(&count)
+		if err == nil && uint64(addr)+uint64(count) > uint64(memSize) {
+			return nil, fmt.Errorf("снимок поврежден: участок памяти [%d, %d) вне памяти", addr, uint64(addr)+uint64(count))
+		}
+		if err == nil {
+			get(vm.Memory[addr : addr+count])
+		}
+	}
+
+	if version >= 3 {
+		var count uint32
+		get(&count)
+		for i := uint32(0); i < count && err == nil; i++ {
+			var state DeviceState
+			var nameLen uint8
+			var n uint32
+			get(&state.Base)
+			get(&nameLen)
+			name := make([]byte, nameLen)
+			get(name)
+			get(&n)
+			if err == nil && n > maxDeviceState {
+				return nil, fmt.Errorf("снимок поврежден: состояние устройства %s из %d значений", name, n)
+			}
+			state.Name, state.State = string(name), make([]uint32, n)
+			get(state.State)
+			vm.PendingDevices = append(vm.PendingDevices, state)
+		}
+	}
+
+	get(&codeLen)
+	if err == nil && int(codeLen) != r.Len() {
+		return nil, fmt.Errorf("снимок поврежден: размер программы %d байт", codeLen)
+	}
+	vm.Code = make([]byte, codeLen)
+	get(vm.Code)
+	if err != nil {
+		return nil, fmt.Errorf("снимок поврежден: %v", err)
+	}
+
+	return vm, nil
+}
+
+// SaveSnapshot записывает снимок состояния в файл
+func (vm *VM) SaveSnapshot(path string) error {
+	if len(vm.Memory) > MaxSnapshotMemory {
+		return fmt.Errorf("память %d ячеек больше %d: снимок нельзя будет загрузить", len(vm.Memory), MaxSnapshotMemory)
+	}
+	return os.WriteFile(path, vm.Snapshot(), 0644)
+}
+
+// LoadSnapshot создает машину из файла снимка
+func LoadSnapshot(path string) (*VM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vm, err := RestoreSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return vm, nil
+}
+
+// nonZeroRuns возвращает участки [начало, конец) из ненулевых слов
+func nonZeroRuns(memory []uint32) [][2]int {
+	var runs [][2]int
+	for i := 0; i < len(memory); {
+		if memory[i] == 0 {
+			i++
+			continue
+		}
+		start := i
+		for i < len(memory) && memory[i] != 0 {
+			i++
+		}
+		runs = append(runs, [2]int{start, i})
+	}
+	return runs
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
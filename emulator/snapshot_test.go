@@ -0,0 +1,74 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+	"uvm-assembler/assembler"
+)
+
+func newTestMachine(t *testing.T, source string) *VM {
+	t.Helper()
+	program, err := assembler.NewParser(source).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := program.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := New(img, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	vm := newTestMachine(t, "LOAD R1 7\nLOAD R2 3\nWRITE R1 R2\nSQRT R1 5\n")
+	for i := 0; i < 3; i++ {
+		if err := vm.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := vm.Snapshot()
+	restored, err := RestoreSnapshot(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.PC != vm.PC || restored.Steps != vm.Steps || restored.Regs != vm.Regs ||
+		restored.Model != vm.Model || !bytes.Equal(restored.Code, vm.Code) {
+		t.Errorf("восстановлено другое состояние")
+	}
+	for i := range vm.Memory {
+		if restored.Memory[i] != vm.Memory[i] {
+			t.Errorf("ячейка %d = %d, ожидалось %d", i, restored.Memory[i], vm.Memory[i])
+		}
+	}
+	if !bytes.Equal(restored.Snapshot(), data) {
+		t.Errorf("снимок восстановленной машины отличается от исходного")
+	}
+}
+
+// TestSnapshotMemorySize проверяет, что снимок с огромным размером памяти
+// отклоняется до выделения памяти
+func TestSnapshotMemorySize(t *testing.T) {
+	data := newTestMachine(t, "LOAD R1 1\n").Snapshot()
+
+	// Размер памяти - после заголовка, PC, признака завершения, модели и регистров
+	offset := len(snapshotMagic) + 2 + 8 + 4 + 1 + 3 + 4*RegisterCount
+	for _, size := range []uint32{MaxSnapshotMemory + 1, 0xFFFFFFFF} {
+		corrupted := bytes.Clone(data)
+		binary.LittleEndian.PutUint32(corrupted[offset:], size)
+		body := corrupted[:len(corrupted)-4]
+		binary.LittleEndian.PutUint32(corrupted[len(body):], crc32.ChecksumIEEE(body))
+
+		_, err := RestoreSnapshot(corrupted)
+		if err == nil || !strings.Contains(err.Error(), "размер памяти") {
+			t.Errorf("размер %d: ошибка %v, ожидалась ошибка размера памяти", size, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SqrtMode - числовая семантика команды SQRT. Спецификация говорит только
+// "sqrt()", поэтому эмулятор поддерживает несколько распространенных трактовок.
+type SqrtMode string
+
+const (
+	SqrtFloor SqrtMode = "floor"   // целый корень с округлением вниз (по умолчанию)
+	SqrtRound SqrtMode = "round"   // целый корень с округлением до ближайшего
+	SqrtQ16   SqrtMode = "q16"     // число с фиксированной точкой Q16.16, округление вниз
+	SqrtFloat SqrtMode = "float32" // биты числа IEEE 754 одинарной точности
+)
+
+// SqrtModes - допустимые режимы SQRT
+var SqrtModes = []string{string(SqrtFloor), string(SqrtRound), string(SqrtQ16), string(SqrtFloat)}
+
+// ParseSqrtMode проверяет название режима SQRT
+func ParseSqrtMode(name string) (SqrtMode, error) {
+	for _, m := range SqrtModes {
+		if name == m {
+			return SqrtMode(name), nil
+		}
+	}
+	return "", fmt.Errorf("неизвестный режим SQRT: %s (допустимо: %s)", name, strings.Join(SqrtModes, ", "))
+}
+
+// Sqrt вычисляет результат SQRT для значения регистра x. При signed целые режимы
+// считают старший бит знаком, и корень из отрицательного числа - ошибка. В режиме
+// float32 ошибка - отрицательное число (кроме -0) и NaN.
+func Sqrt(mode SqrtMode, signed bool, x uint32) (uint32, error) {
+	if mode == SqrtFloat {
+		f := math.Float32frombits(x)
+		if f < 0 || f != f {
+			return 0, &SqrtError{Value: x, Mode: mode}
+		}
+		return math.Float32bits(float32(math.Sqrt(float64(f)))), nil
+	}
+
+	if signed && int32(x) < 0 {
+		return 0, &SqrtError{Value: x, Mode: mode}
+	}
+	switch mode {
+	case SqrtRound:
+		// (r + 1/2)^2 = r^2 + r + 1/4, поэтому округляем вверх при x - r^2 > r
+		r := isqrt(uint64(x))
+		if uint64(x)-r*r > r {
+			r++
+		}
+		return uint32(r), nil
+	case SqrtQ16:
+		// sqrt(x / 2^16) * 2^16 = sqrt(x * 2^16)
+		return uint32(isqrt(uint64(x) << 16)), nil
+	default:
+		return uint32(isqrt(uint64(x))), nil
+	}
+}
+
+// isqrt возвращает floor(sqrt(x)) для x < 2^52
+func isqrt(x uint64) uint64 {
+	r := uint64(math.Sqrt(float64(x)))
+	for r*r > x {
+		r--
+	}
+	for (r+1)*(r+1) <= x {
+		r++
+	}
+	return r
+}
+
+// SqrtVector - эталонный результат SQRT
+type SqrtVector struct {
+	Mode   SqrtMode
+	Signed bool
+	In     uint32
+	Out    uint32
+	Fault  bool // ожидается ошибка FaultSqrt
+}
+
+// SqrtVectors - эталонные значения для всех режимов SQRT: границы диапазонов,
+// точные квадраты и соседние с ними значения, половинные случаи округления.
+// Проверяются тестами TestSqrtVectors и TestSqrtCommand и командой uvm-assembler sqrt -vectors.
+var SqrtVectors = []SqrtVector{
+	{Mode: SqrtFloor, In: 0, Out: 0},
+	{Mode: SqrtFloor, In: 1, Out: 1},
+	{Mode: SqrtFloor, In: 3, Out: 1},
+	{Mode: SqrtFloor, In: 4, Out: 2},
+	{Mode: SqrtFloor, In: 80, Out: 8},
+	{Mode: SqrtFloor, In: 81, Out: 9},
+	{Mode: SqrtFloor, In: 65535, Out: 255},
+	{Mode: SqrtFloor, In: 65536, Out: 256},
+	{Mode: SqrtFloor, In: 4294836224, Out: 65534},
+	{Mode: SqrtFloor, In: 4294836225, Out: 65535},
+	{Mode: SqrtFloor, In: 0xFFFFFFFF, Out: 65535},
+	{Mode: SqrtFloor, Signed: true, In: 0x7FFFFFFF, Out: 46340},
+	{Mode: SqrtFloor, Signed: true, In: 0xFFFFFFFB, Fault: true},
+
+	{Mode: SqrtRound, In: 0, Out: 0},
+	{Mode: SqrtRound, In: 2, Out: 1},
+	{Mode: SqrtRound, In: 3, Out: 2},
+	{Mode: SqrtRound, In: 6, Out: 2},
+	{Mode: SqrtRound, In: 7, Out: 3},
+	{Mode: SqrtRound, In: 72, Out: 8},
+	{Mode: SqrtRound, In: 73, Out: 9},
+	{Mode: SqrtRound, In: 81, Out: 9},
+	{Mode: SqrtRound, In: 4294901760, Out: 65535},
+	{Mode: SqrtRound, In: 4294901761, Out: 65536},
+	{Mode: SqrtRound, In: 0xFFFFFFFF, Out: 65536},
+	{Mode: SqrtRound, Signed: true, In: 0x80000000, Fault: true},
+
+	{Mode: SqrtQ16, In: 0, Out: 0},
+	{Mode: SqrtQ16, In: 1, Out: 0x100},             // 2^-16 -> 2^-8
+	{Mode: SqrtQ16, In: 0x4000, Out: 0x8000},       // 0.25 -> 0.5
+	{Mode: SqrtQ16, In: 0x10000, Out: 0x10000},     // 1.0 -> 1.0
+	{Mode: SqrtQ16, In: 0x20000, Out: 0x16A09},     // 2.0 -> 1.41421
+	{Mode: SqrtQ16, In: 0x24000, Out: 0x18000},     // 2.25 -> 1.5
+	{Mode: SqrtQ16, In: 0x40000, Out: 0x20000},     // 4.0 -> 2.0
+	{Mode: SqrtQ16, In: 0xFFFFFFFF, Out: 0xFFFFFF}, // 65535.99998 -> 255.99998
+	{Mode: SqrtQ16, Signed: true, In: 0xFFFF0000, Fault: true},
+
+	{Mode: SqrtFloat, In: 0x00000000, Out: 0x00000000}, // 0 -> 0
+	{Mode: SqrtFloat, In: 0x80000000, Out: 0x80000000}, // -0 -> -0
+	{Mode: SqrtFloat, In: 0x3E800000, Out: 0x3F000000}, // 0.25 -> 0.5
+	{Mode: SqrtFloat, In: 0x3F800000, Out: 0x3F800000}, // 1 -> 1
+	{Mode: SqrtFloat, In: 0x40000000, Out: 0x3FB504F3}, // 2 -> 1.4142135
+	{Mode: SqrtFloat, In: 0x40800000, Out: 0x40000000}, // 4 -> 2
+	{Mode: SqrtFloat, In: 0x00000001, Out: 0x1A3504F3}, // 2^-149 -> 2^-74.5
+	{Mode: SqrtFloat, In: 0x7F800000, Out: 0x7F800000}, // +Inf -> +Inf
+	{Mode: SqrtFloat, In: 0xBF800000, Fault: true},     // -1
+	{Mode: SqrtFloat, In: 0x7FC00000, Fault: true},     // NaN
+}
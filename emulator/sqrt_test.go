@@ -0,0 +1,59 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"uvm-assembler/assembler"
+)
+
+func TestSqrtVectors(t *testing.T) {
+	for _, v := range SqrtVectors {
+		got, err := Sqrt(v.Mode, v.Signed, v.In)
+		switch {
+		case v.Fault && err == nil:
+			t.Errorf("%s signed=%v sqrt(0x%08X) = 0x%08X, ожидалась ошибка", v.Mode, v.Signed, v.In, got)
+		case !v.Fault && err != nil:
+			t.Errorf("%s signed=%v sqrt(0x%08X): %v", v.Mode, v.Signed, v.In, err)
+		case !v.Fault && got != v.Out:
+			t.Errorf("%s signed=%v sqrt(0x%08X) = 0x%08X, ожидалось 0x%08X", v.Mode, v.Signed, v.In, got, v.Out)
+		}
+	}
+}
+
+// TestSqrtCommand проверяет те же значения через команду SQRT на обоих ядрах
+func TestSqrtCommand(t *testing.T) {
+	program, err := assembler.NewParser("SQRT R1 7\n").ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := program.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range SqrtVectors {
+		for name, run := range map[string]func(vm *VM) error{"interpreter": interpret, "fast": runFast} {
+			vm, err := New(img, 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vm.SqrtMode, vm.SqrtSigned = v.Mode, v.Signed
+			vm.Regs[1] = v.In
+			err = run(vm)
+
+			vector := fmt.Sprintf("%s: %s signed=%v sqrt(0x%08X)", name, v.Mode, v.Signed, v.In)
+			var fault *Fault
+			switch {
+			case v.Fault:
+				if !errors.As(err, &fault) || fault.Kind != FaultSqrt {
+					t.Errorf("%s: ошибка %v, ожидалась FaultSqrt", vector, err)
+				}
+			case err != nil:
+				t.Errorf("%s: %v", vector, err)
+			case vm.Memory[7] != v.Out:
+				t.Errorf("%s = 0x%08X, ожидалось 0x%08X", vector, vm.Memory[7], v.Out)
+			}
+		}
+	}
+}
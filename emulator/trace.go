@@ -0,0 +1,236 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"uvm-assembler/assembler"
+)
+
+// RegChange - изменение регистра, выполненное командой
+type RegChange struct {
+	Reg int    `json:"reg"`
+	Old uint32 `json:"old"`
+	New uint32 `json:"new"`
+}
+
+// TraceEntry - запись трассировки одной выполненной команды
+type TraceEntry struct {
+	Step        uint64                    `json:"step"`
+	PC          uint32                    `json:"pc"`
+	Instruction string                    `json:"instruction"`
+	Fields      map[string]uint32         `json:"fields"`
+	Regs        []RegChange               `json:"regs,omitempty"`
+	Accesses    []Access                  `json:"mem,omitempty"`
+	Source      *assembler.SourceLocation `json:"source,omitempty"`
+	Error       string                    `json:"error,omitempty"` // ошибка, на которой команда остановилась
+}
+
+// Tracer получает записи о каждой выполненной команде
+type Tracer interface {
+	Trace(entry TraceEntry) error
+	Close() error
+}
+
+// MarshalText позволяет записывать вид обращения в JSON строкой
+func (k AccessKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// TraceFormats - поддерживаемые форматы трассировки
+var TraceFormats = []string{"text", "jsonl", "chrome"}
+
+// NewTracer создает трассировщик в заданном формате
+func NewTracer(format string, w io.Writer) (Tracer, error) {
+	switch format {
+	case "text":
+		return &textTracer{w: w}, nil
+	case "jsonl":
+		return &jsonlTracer{enc: json.NewEncoder(w)}, nil
+	case "chrome":
+		return &chromeTracer{w: w}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат трассировки: %s (допустимо: %s)", format, strings.Join(TraceFormats, ", "))
+	}
+}
+
+// traceStep выполняет команду и передает запись о ней трассировщику.
+// Команда, выполнение которой закончилось ошибкой, тоже записывается - с текстом ошибки.
+func (vm *VM) traceStep(cmd assembler.Command, execute func() error) error {
+	entry := TraceEntry{
+		Step:        vm.Steps,
+		PC:          vm.PC,
+		Instruction: assembler.Disassemble(cmd),
+		Fields:      cmd.FieldMap(),
+	}
+	if loc, ok := vm.Debug.Lookup(vm.PC); ok {
+		entry.Source = &loc.SourceLocation
+	}
+
+	before := vm.Regs
+	err := execute()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	for i := range vm.Regs {
+		if vm.Regs[i] != before[i] {
+			entry.Regs = append(entry.Regs, RegChange{Reg: i, Old: before[i], New: vm.Regs[i]})
+		}
+	}
+	entry.Accesses = append([]Access(nil), vm.Accesses...)
+	if traceErr := vm.Tracer.Trace(entry); err == nil {
+		err = traceErr
+	}
+	return err
+}
+
+// textTracer записывает трассировку в читаемом виде
+type textTracer struct {
+	w io.Writer
+}
+
+func (t *textTracer) Trace(e TraceEntry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%6d  PC=%-5d %-22s", e.Step, e.PC, e.Instruction)
+
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=%d", name, e.Fields[name])
+	}
+
+	for _, r := range e.Regs {
+		fmt.Fprintf(&b, " | R%d: %d -> %d", r.Reg, r.Old, r.New)
+	}
+	for _, a := range e.Accesses {
+		switch {
+		case a.Device != "" && a.Kind == AccessWrite:
+			fmt.Fprintf(&b, " | %s[%d] <- %d", a.Device, a.Addr, a.Value)
+		case a.Device != "":
+			fmt.Fprintf(&b, " | %s[%d] = %d", a.Device, a.Addr, a.Value)
+		case a.Kind == AccessWrite:
+			fmt.Fprintf(&b, " | mem[%d]: %d -> %d", a.Addr, a.Old, a.Value)
+		default:
+			fmt.Fprintf(&b, " | mem[%d] = %d", a.Addr, a.Value)
+		}
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&b, " | ошибка: %s", e.Error)
+	}
+	if e.Source != nil {
+		fmt.Fprintf(&b, "  ; %s", e.Source)
+	}
+
+	_, err := fmt.Fprintln(t.w, b.String())
+	return err
+}
+
+func (t *textTracer) Close() error {
+	return nil
+}
+
+// jsonlTracer записывает по одному JSON-объекту на команду (JSON Lines)
+type jsonlTracer struct {
+	enc *json.Encoder
+}
+
+func (t *jsonlTracer) Trace(e TraceEntry) error {
+	return t.enc.Encode(e)
+}
+
+func (t *jsonlTracer) Close() error {
+	return nil
+}
+
+// chromeTracer записывает события в формате Chrome trace_event:
+// каждая команда - полное событие длительностью 1 мкс, запись в память -
+// мгновенное событие. Файл открывается в chrome://tracing или Perfetto.
+type chromeTracer struct {
+	w       io.Writer
+	started bool
+	err     error
+}
+
+type chromeEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat"`
+	Phase string         `json:"ph"`
+	TS    uint64         `json:"ts"`
+	Dur   uint64         `json:"dur,omitempty"`
+	PID   int            `json:"pid"`
+	TID   int            `json:"tid"`
+	Scope string         `json:"s,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+func (t *chromeTracer) Trace(e TraceEntry) error {
+	args := map[string]any{"pc": e.PC, "fields": e.Fields}
+	if len(e.Regs) > 0 {
+		args["regs"] = e.Regs
+	}
+	if len(e.Accesses) > 0 {
+		args["mem"] = e.Accesses
+	}
+	if e.Source != nil {
+		args["source"] = e.Source.String()
+	}
+	if e.Error != "" {
+		args["error"] = e.Error
+	}
+
+	name := e.Instruction
+	if idx := strings.IndexByte(name, ' '); idx > 0 {
+		name = name[:idx]
+	}
+	t.event(chromeEvent{Name: name, Cat: "instruction", Phase: "X", TS: e.Step, Dur: 1, PID: 1, TID: 1, Args: args})
+
+	for _, a := range e.Accesses {
+		if a.Kind == AccessWrite {
+			name := fmt.Sprintf("mem[%d] = %d", a.Addr, a.Value)
+			if a.Device != "" {
+				name = fmt.Sprintf("%s <- %d", a.Device, a.Value)
+			}
+			t.event(chromeEvent{
+				Name: name, Cat: "memory", Phase: "i",
+				TS: e.Step, PID: 1, TID: 1, Scope: "t",
+			})
+		}
+	}
+	return t.err
+}
+
+func (t *chromeTracer) event(ev chromeEvent) {
+	if t.err != nil {
+		return
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	prefix := ",\n"
+	if !t.started {
+		prefix = "{\"displayTimeUnit\":\"ns\",\"traceEvents\":[\n"
+		t.started = true
+	}
+	_, t.err = fmt.Fprintf(t.w, "%s%s", prefix, data)
+}
+
+func (t *chromeTracer) Close() error {
+	if t.err != nil {
+		return t.err
+	}
+	if !t.started {
+		_, err := fmt.Fprint(t.w, "{\"traceEvents\":[]}\n")
+		return err
+	}
+	_, err := fmt.Fprint(t.w, "\n]}\n")
+	return err
+}
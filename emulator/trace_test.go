@@ -0,0 +1,34 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestTraceFault проверяет, что команда с ошибкой выполнения попадает в трассировку
+func TestTraceFault(t *testing.T) {
+	vm := newTestMachine(t, "LOAD R1 5\nLOAD R2 1000\nREAD R3 0 R2\nLOAD R4 1\n")
+	var out bytes.Buffer
+	tracer, err := NewTracer("jsonl", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Tracer = tracer
+	if err := vm.Run(); err == nil {
+		t.Fatal("ожидалась ошибка выполнения")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("записано %d команд, ожидалось 3:\n%s", len(lines), out.String())
+	}
+	var last TraceEntry
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.PC != 2 || last.Instruction != "READ R3 0 R2" || !strings.Contains(last.Error, "за пределами памяти") {
+		t.Errorf("последняя запись: %+v", last)
+	}
+}
@@ -0,0 +1,335 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"uvm-assembler/assembler"
+)
+
+const (
+	RegisterCount     = 64
+	DefaultMemorySize = 1 << 16 // слов данных
+)
+
+// ErrHalted возвращается при попытке выполнить команду после завершения программы
+var ErrHalted = errors.New("программа завершена")
+
+// AccessKind - вид обращения к памяти
+type AccessKind int
+
+const (
+	AccessRead AccessKind = iota + 1
+	AccessWrite
+)
+
+func (k AccessKind) String() string {
+	if k == AccessWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// Access - обращение к памяти данных, выполненное командой
+type Access struct {
+	Kind  AccessKind `json:"kind"`
+	Addr  uint32     `json:"addr"`
+	Value uint32     `json:"value"` // прочитанное или записанное значение
+	Old   uint32     `json:"old"`   // значение до записи
+	// Device - имя устройства, если адрес принадлежит регистру устройства
+	Device string `json:"device,omitempty"`
+}
+
+// VM - учебная виртуальная машина: 64 регистра, память данных из слов
+// и программа из 5-байтовых команд. PC - номер очередной команды.
+type VM struct {
+	Regs   [RegisterCount]uint32
+	PC     uint32
+	Memory []uint32 // ячейки памяти данных (см. MemoryModel)
+	Model  MemoryModel
+	Code   []byte
+	Steps  uint64
+	Halted bool
+
+	// SqrtMode - числовая семантика SQRT (пусто - SqrtFloor). SqrtSigned - считать
+	// аргумент целых режимов знаковым: корень из отрицательного значения - FaultSqrt
+	SqrtMode   SqrtMode
+	SqrtSigned bool
+
+	// Accesses - обращения к памяти последней выполненной команды
+	Accesses []Access
+
+	// Devices - устройства, подключенные к адресам памяти данных (см. Attach).
+	// Exited и ExitCode устанавливаются, когда программа завершилась через порт останова.
+	Devices  []DeviceMapping
+	Exited   bool
+	ExitCode uint32
+	jump     *uint32 // адрес следующей команды, заданный устройством (см. JumpRequest)
+
+	// PendingDevices - состояния устройств из снимка; состояние возвращается
+	// устройству при подключении с тем же именем и адресом
+	PendingDevices []DeviceState
+
+	Debug   *assembler.DebugInfo // необязательная таблица строк для сообщений и трассировки
+	Tracer  Tracer               // необязательный трассировщик выполненных команд
+	Profile *Profile             // необязательные счетчики профилировщика
+}
+
+// New создает машину с памятью из memorySize 32-битных слов и загружает в нее программу
+func New(img *assembler.Image, memorySize int) (*VM, error) {
+	model := DefaultMemoryModel()
+	if memorySize > 0 {
+		model.Size = memorySize
+	}
+	return NewWithModel(img, model)
+}
+
+// NewWithModel создает машину с заданной моделью памяти и загружает в нее программу:
+// код, начальные данные и точку входа. При адресации по байтам слово данных
+// с номером i секции помещается по адресу (Addr+i)*WordBits/8.
+func NewWithModel(img *assembler.Image, model MemoryModel) (*VM, error) {
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+
+	vm := &VM{
+		PC:     img.Entry,
+		Memory: make([]uint32, model.Size),
+		Model:  model,
+		Code:   img.Code(),
+	}
+
+	cells := uint64(model.WordCells())
+	for _, s := range img.DataSections() {
+		words := assembler.DecodeWords(s.Data)
+		start, end := uint64(s.Addr)*cells, (uint64(s.Addr)+uint64(len(words)))*cells
+		if end > uint64(model.Size) {
+			return nil, fmt.Errorf("секция данных [%d, %d) не помещается в память из %d ячеек", start, end, model.Size)
+		}
+		for i, w := range words {
+			vm.poke(uint32(start+uint64(i)*cells), w)
+		}
+	}
+
+	vm.Halted = vm.PC == vm.Len()
+	return vm, nil
+}
+
+// Len возвращает количество команд в программе
+func (vm *VM) Len() uint32 {
+	return uint32(len(vm.Code) / assembler.CommandSize)
+}
+
+// Fetch декодирует команду по адресу
+func (vm *VM) Fetch(addr uint32) (assembler.Command, error) {
+	if addr >= vm.Len() {
+		return assembler.Command{}, fmt.Errorf("адрес команды %d за пределами программы (%d команд)", addr, vm.Len())
+	}
+	start := int(addr) * assembler.CommandSize
+	return assembler.Decode(vm.Code[start : start+assembler.CommandSize])
+}
+
+// ReadMemory читает слово памяти данных по правилам модели памяти
+func (vm *VM) ReadMemory(addr uint32) (uint32, error) {
+	start, err := vm.locate(addr, AccessRead)
+	if err != nil {
+		return 0, err
+	}
+	return vm.peek(start), nil
+}
+
+// WriteMemory записывает слово памяти данных по правилам модели памяти
+func (vm *VM) WriteMemory(addr, value uint32) error {
+	start, err := vm.locate(addr, AccessWrite)
+	if err != nil {
+		return err
+	}
+	vm.poke(start, value)
+	return nil
+}
+
+// load читает память или регистр устройства по команде программы и запоминает обращение
+func (vm *VM) load(addr uint32) (uint32, error) {
+	if dev, reg, ok, err := vm.device(addr); ok {
+		if err != nil {
+			return 0, err
+		}
+		value, err := dev.Read(reg)
+		if err != nil {
+			return 0, fmt.Errorf("устройство %s: %v", dev.Name(), err)
+		}
+		value &= vm.Model.mask()
+		vm.Accesses = append(vm.Accesses, Access{Kind: AccessRead, Addr: addr, Value: value, Device: dev.Name()})
+		return value, nil
+	}
+
+	start, err := vm.locate(addr, AccessRead)
+	if err != nil {
+		return 0, err
+	}
+	value := vm.peek(start)
+	vm.Accesses = append(vm.Accesses, Access{Kind: AccessRead, Addr: start, Value: value})
+	return value, nil
+}
+
+// store записывает память или регистр устройства по команде программы и запоминает
+// обращение. Адрес обращения к памяти - фактический, после применения модели памяти.
+func (vm *VM) store(addr, value uint32) error {
+	if dev, reg, ok, err := vm.device(addr); ok {
+		if err != nil {
+			return err
+		}
+		value &= vm.Model.mask()
+		vm.Accesses = append(vm.Accesses, Access{Kind: AccessWrite, Addr: addr, Value: value, Device: dev.Name()})
+
+		var exit *ExitRequest
+		var jump *JumpRequest
+		switch err := dev.Write(reg, value); {
+		case err == nil:
+		case errors.As(err, &exit):
+			vm.Exited, vm.ExitCode = true, exit.Code
+		case errors.As(err, &jump):
+			vm.jump = &jump.Target
+		default:
+			return fmt.Errorf("устройство %s: %w", dev.Name(), err)
+		}
+		return nil
+	}
+
+	start, err := vm.locate(addr, AccessWrite)
+	if err != nil {
+		return err
+	}
+	old := vm.peek(start)
+	vm.poke(start, value)
+	vm.Accesses = append(vm.Accesses, Access{Kind: AccessWrite, Addr: start, Value: value & vm.Model.mask(), Old: old})
+	return nil
+}
+
+// Step выполняет одну команду
+func (vm *VM) Step() error {
+	if vm.Halted {
+		return ErrHalted
+	}
+
+	vm.Accesses = vm.Accesses[:0]
+	if vm.PC >= vm.Len() {
+		return vm.fault(FaultPC, nil, fmt.Errorf("адрес команды %d за пределами программы (%d команд)", vm.PC, vm.Len()))
+	}
+	cmd, err := vm.Fetch(vm.PC)
+	if err != nil {
+		return vm.fault(FaultInvalidOpcode, nil, err)
+	}
+
+	if vm.Tracer != nil {
+		err = vm.traceStep(cmd, func() error { return vm.execute(cmd) })
+	} else {
+		err = vm.execute(cmd)
+	}
+	if err != nil {
+		return vm.classify(cmd, err)
+	}
+
+	if vm.Profile != nil {
+		vm.Profile.record(vm.PC, cmd.Type, vm.Accesses)
+	}
+	if vm.jump != nil {
+		vm.PC, vm.jump = *vm.jump, nil
+	} else {
+		vm.PC++
+	}
+	vm.Steps++
+
+	if !vm.Exited {
+		err = vm.devicesStep()
+	}
+	vm.Halted = vm.PC == vm.Len() || vm.Exited
+	return err
+}
+
+// devicesStep продвигает время устройств и передает управление обработчику
+// прерывания, если оно произошло. Прерывание происходит между командами,
+// поэтому после Step PC уже указывает на первую команду обработчика.
+func (vm *VM) devicesStep() error {
+	for _, m := range vm.Devices {
+		if t, ok := m.Device.(Ticker); ok {
+			t.Tick()
+		}
+	}
+	for _, m := range vm.Devices {
+		if i, ok := m.Device.(Interrupter); ok {
+			if err := vm.interrupt(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// interrupt опрашивает источник прерываний; ошибка чтения вектора - FaultMemory
+func (vm *VM) interrupt(i Interrupter) error {
+	if err := i.Interrupt(vm); err != nil {
+		var memErr *MemoryError
+		if errors.As(err, &memErr) {
+			return vm.fault(FaultMemory, nil, err)
+		}
+		return fmt.Errorf("адрес %d: %v", vm.PC, err)
+	}
+	return nil
+}
+
+// classify превращает ошибку выполнения команды в Fault. Превышение лимита
+// возвращается как есть, прочие ошибки (например, ошибки записи трассы) - с адресом команды.
+func (vm *VM) classify(cmd assembler.Command, err error) error {
+	var memErr *MemoryError
+	var sqrtErr *SqrtError
+	var limitErr *LimitError
+	switch {
+	case errors.As(err, &memErr):
+		return vm.fault(FaultMemory, &cmd, err)
+	case errors.As(err, &sqrtErr):
+		return vm.fault(FaultSqrt, &cmd, err)
+	case errors.As(err, &limitErr):
+		return limitErr
+	}
+	return fmt.Errorf("адрес %d: %s: %v", vm.PC, assembler.Disassemble(cmd), err)
+}
+
+// Run выполняет программу до завершения или ошибки
+func (vm *VM) Run() error {
+	for !vm.Halted {
+		if err := vm.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execute выполняет декодированную команду
+func (vm *VM) execute(cmd assembler.Command) error {
+	f := cmd.Fields
+	switch cmd.Type {
+	case assembler.LOAD_CONST:
+		// R[B] = C
+		vm.Regs[f[assembler.FieldB]] = f[assembler.FieldC]
+	case assembler.READ_MEM:
+		// R[D] = mem[R[C] + B]
+		value, err := vm.load(vm.Regs[f[assembler.FieldC]] + f[assembler.FieldB])
+		if err != nil {
+			return err
+		}
+		vm.Regs[f[assembler.FieldD]] = value
+	case assembler.WRITE_MEM:
+		// mem[R[C]] = R[B]
+		return vm.store(vm.Regs[f[assembler.FieldC]], vm.Regs[f[assembler.FieldB]])
+	case assembler.SQRT_OP:
+		// mem[C] = sqrt(R[B])
+		value, err := Sqrt(vm.SqrtMode, vm.SqrtSigned, vm.Regs[f[assembler.FieldB]])
+		if err != nil {
+			return err
+		}
+		return vm.store(f[assembler.FieldC], value)
+	default:
+		return fmt.Errorf("неизвестная команда: %d", cmd.Type)
+	}
+	return nil
+}
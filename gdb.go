@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"uvm-assembler/gdbstub"
+)
+
+// runGDB запускает сервер GDB Remote Serial Protocol для программы
+func runGDB(args []string) {
+	fs := flag.NewFlagSet("gdb", flag.ExitOnError)
+	listen := fs.String("listen", "localhost:1234", "Адрес для подключения GDB (target remote)")
+	memoryModel := memoryFlags(fs)
+	sqrtOptions := sqrtFlags(fs)
+	devices := deviceFlags(fs)
+	loadSnapshot := fs.String("load-snapshot", "", "Начать с состояния из снимка")
+	verbose := fs.Bool("v", false, "Выводить пакеты протокола")
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler gdb [-listen localhost:1234] program.bin")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 || (fs.NArg() == 0 && *loadSnapshot == "") {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	vm, _ := newMachine(fs.Args(), *loadSnapshot, "", memoryModel())
+	vm.SqrtMode, vm.SqrtSigned = sqrtOptions()
+	devices(vm, nil, os.Stdout)
+	server := gdbstub.New(vm)
+	if *verbose {
+		server.Log = os.Stdout
+	}
+
+	fmt.Printf("🔌 Ожидание GDB на %s (target remote %s)\n", *listen, *listen)
+	if err := server.ListenAndServe(*listen); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Сеанс GDB завершен: выполнено %d команд\n", vm.Steps)
+}
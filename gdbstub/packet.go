@@ -0,0 +1,106 @@
+package gdbstub
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// interruptByte - байт прерывания (Ctrl+C), который GDB посылает вне пакетов
+const interruptByte = 0x03
+
+// message - пакет от GDB или запрос на прерывание
+type message struct {
+	data      string
+	interrupt bool
+}
+
+// readMessages разбирает поток протокола RSP ($данные#кс) и отправляет пакеты
+// в канал. Пакеты с неверной контрольной суммой отклоняются ответом '-'.
+// Канал закрывается при закрытии соединения.
+func (s *Server) readMessages(r io.Reader, out chan<- message) {
+	defer close(out)
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch b {
+		case interruptByte:
+			out <- message{interrupt: true}
+		case '$':
+			data, err := br.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+
+			sum := make([]byte, 2)
+			if _, err := io.ReadFull(br, sum); err != nil {
+				return
+			}
+			want, err := strconv.ParseUint(string(sum), 16, 8)
+			if err != nil || uint8(want) != checksum(data) {
+				s.logf("пакет с неверной контрольной суммой: %q", data)
+				s.writeRaw("-")
+				continue
+			}
+			if !s.noAck.Load() {
+				s.writeRaw("+")
+			}
+			out <- message{data: unescape(data)}
+		default:
+			// '+' и '-' подтверждают наши пакеты; повторную отправку не поддерживаем
+		}
+	}
+}
+
+// send отправляет пакет с ответом
+func (s *Server) send(data string) error {
+	s.logf("<- %s", data)
+	return s.writeRaw(fmt.Sprintf("$%s#%02x", data, checksum(data)))
+}
+
+func (s *Server) writeRaw(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.conn, data)
+	return err
+}
+
+func checksum(data string) uint8 {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+// escape экранирует двоичные данные ответа: символы #$}* передаются как '}' и байт ^ 0x20
+func escape(data []byte) string {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == '#' || b == '$' || b == '}' || b == '*' {
+			out = append(out, '}', b^0x20)
+		} else {
+			out = append(out, b)
+		}
+	}
+	return string(out)
+}
+
+func unescape(data string) string {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^0x20)
+		} else {
+			out = append(out, data[i])
+		}
+	}
+	return string(out)
+}
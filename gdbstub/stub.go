@@ -0,0 +1,507 @@
+// Package gdbstub реализует сервер протокола GDB Remote Serial Protocol для УВМ,
+// чтобы программы можно было отлаживать из GDB или любого клиента RSP.
+package gdbstub
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"uvm-assembler/assembler"
+	"uvm-assembler/emulator"
+)
+
+// Сигналы в ответах об остановке
+const (
+	sigInt  = 2
+	sigIll  = 4
+	sigTrap = 5
+	sigFpe  = 8
+	sigSegv = 11
+)
+
+// interruptCheckInterval - через сколько команд continue проверяет запрос на прерывание
+const interruptCheckInterval = 1024
+
+// Server - отладочный сервер RSP для одной машины
+type Server struct {
+	vm          *emulator.VM
+	breakpoints map[uint32]bool
+	Log         io.Writer // необязательный журнал пакетов
+
+	conn  io.ReadWriter
+	mu    sync.Mutex // запись в соединение
+	noAck atomic.Bool
+}
+
+// New создает сервер для машины
+func New(vm *emulator.VM) *Server {
+	return &Server{vm: vm, breakpoints: map[uint32]bool{}}
+}
+
+// ListenAndServe ждет подключения GDB по TCP и обслуживает его до отключения
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	s.logf("ожидание подключения GDB на %s", listener.Addr())
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s.logf("подключен %s", conn.RemoteAddr())
+	return s.Serve(conn)
+}
+
+// Serve обслуживает одно соединение до отключения или команды k/D
+func (s *Server) Serve(conn io.ReadWriter) error {
+	s.conn = conn
+	s.noAck.Store(false)
+
+	messages := make(chan message, 16)
+	go s.readMessages(conn, messages)
+
+	for msg := range messages {
+		if msg.interrupt {
+			// Машина уже остановлена: просто сообщаем об этом
+			if err := s.send(stopReply(sigInt)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s.logf("-> %s", msg.data)
+		reply, done := s.handle(msg.data, messages)
+		if err := s.send(reply); err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	return nil
+}
+
+// handle выполняет пакет и возвращает ответ; done означает конец сеанса
+func (s *Server) handle(packet string, messages <-chan message) (reply string, done bool) {
+	if packet == "" {
+		return "", false
+	}
+	args := packet[1:]
+
+	switch packet[0] {
+	case '?':
+		return s.status(), false
+	case 'g':
+		return s.readRegisters(), false
+	case 'G':
+		return s.writeRegisters(args), false
+	case 'p':
+		return s.readRegister(args), false
+	case 'P':
+		return s.writeRegister(args), false
+	case 'm':
+		return s.readMemory(args), false
+	case 'M':
+		return s.writeMemory(args), false
+	case 's':
+		return s.step(args), false
+	case 'c':
+		return s.cont(args, messages), false
+	case 'Z', 'z':
+		return s.breakpoint(packet[0] == 'Z', args), false
+	case 'H', 'T':
+		return "OK", false
+	case 'q', 'Q':
+		return s.query(packet), false
+	case 'k':
+		return "OK", true
+	case 'D':
+		return "OK", true
+	}
+	return "", false
+}
+
+// query обрабатывает общие запросы q/Q
+func (s *Server) query(packet string) string {
+	switch {
+	case strings.HasPrefix(packet, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+;swbreak+;QStartNoAckMode+"
+	case packet == "QStartNoAckMode":
+		s.noAck.Store(true)
+		return "OK"
+	case strings.HasPrefix(packet, "qXfer:features:read:"):
+		return s.readFeatures(strings.TrimPrefix(packet, "qXfer:features:read:"))
+	case packet == "qAttached":
+		return "1"
+	case packet == "qC":
+		return "QC1"
+	case packet == "qfThreadInfo":
+		return "m1"
+	case packet == "qsThreadInfo":
+		return "l"
+	case packet == "qOffsets":
+		return "Text=0;Data=0;Bss=0"
+	}
+	return ""
+}
+
+// readFeatures отдает описание цели частями: annex:смещение,длина
+func (s *Server) readFeatures(args string) string {
+	annex, rest, ok := strings.Cut(args, ":")
+	if !ok || annex != "target.xml" {
+		return "E00"
+	}
+	offset, length, err := parseRange(rest)
+	if err != nil {
+		return "E01"
+	}
+
+	xml := targetXML()
+	if offset >= uint64(len(xml)) {
+		return "l"
+	}
+	end := offset + length
+	if end >= uint64(len(xml)) {
+		return "l" + escape([]byte(xml[offset:]))
+	}
+	return "m" + escape([]byte(xml[offset:end]))
+}
+
+// status возвращает ответ об остановке для текущего состояния машины
+func (s *Server) status() string {
+	if s.vm.Halted {
+		return s.exitReply()
+	}
+	return stopReply(sigTrap)
+}
+
+// faultSignal сопоставляет ошибке выполнения сигнал: неверный код операции - SIGILL,
+// недопустимый аргумент SQRT - SIGFPE, остальное - SIGSEGV
+func faultSignal(err error) int {
+	var fault *emulator.Fault
+	if errors.As(err, &fault) {
+		switch fault.Kind {
+		case emulator.FaultInvalidOpcode:
+			return sigIll
+		case emulator.FaultSqrt:
+			return sigFpe
+		}
+	}
+	return sigSegv
+}
+
+// exitReply сообщает о завершении программы с кодом, записанным в порт останова
+func (s *Server) exitReply() string {
+	return fmt.Sprintf("W%02x", s.vm.ExitCode&0xFF)
+}
+
+func stopReply(signal int) string {
+	return fmt.Sprintf("S%02x", signal)
+}
+
+func (s *Server) readRegisters() string {
+	var b strings.Builder
+	for i := 0; i <= pcRegister; i++ {
+		b.WriteString(encodeWord(s.registerValue(i)))
+	}
+	return b.String()
+}
+
+func (s *Server) writeRegisters(args string) string {
+	if len(args) < (pcRegister+1)*8 {
+		return "E01"
+	}
+	for i := 0; i <= pcRegister; i++ {
+		value, err := decodeWord(args[i*8 : i*8+8])
+		if err != nil {
+			return "E01"
+		}
+		s.setRegister(i, value)
+	}
+	return "OK"
+}
+
+func (s *Server) readRegister(args string) string {
+	reg, err := strconv.ParseUint(args, 16, 32)
+	if err != nil || reg > pcRegister {
+		return "E01"
+	}
+	return encodeWord(s.registerValue(int(reg)))
+}
+
+func (s *Server) writeRegister(args string) string {
+	regText, valueText, ok := strings.Cut(args, "=")
+	reg, err := strconv.ParseUint(regText, 16, 32)
+	if !ok || err != nil || reg > pcRegister {
+		return "E01"
+	}
+	value, err := decodeWord(valueText)
+	if err != nil {
+		return "E01"
+	}
+	s.setRegister(int(reg), value)
+	return "OK"
+}
+
+// registerValue возвращает значение регистра; pc - адрес команды в области кода
+func (s *Server) registerValue(reg int) uint32 {
+	if reg == pcRegister {
+		return CodeBase + s.vm.PC*assembler.CommandSize
+	}
+	return s.vm.Regs[reg]
+}
+
+// setRegister записывает регистр; pc принимается адресом в области кода
+// или номером команды
+func (s *Server) setRegister(reg int, value uint32) {
+	if reg == pcRegister {
+		s.setPC(codeAddress(uint64(value)))
+		return
+	}
+	s.vm.Regs[reg] = value
+}
+
+func (s *Server) setPC(pc uint32) {
+	s.vm.PC = pc
+	s.vm.Halted = pc == s.vm.Len()
+}
+
+// readMemory обрабатывает m адрес,длина
+func (s *Server) readMemory(args string) string {
+	addr, length, err := parseRange(args)
+	if err != nil {
+		return "E01"
+	}
+
+	var b strings.Builder
+	for i := uint64(0); i < length; i++ {
+		value, ok := s.readByte(addr + i)
+		if !ok {
+			if i == 0 {
+				return "E14"
+			}
+			break // частичное чтение допустимо
+		}
+		fmt.Fprintf(&b, "%02x", value)
+	}
+	return b.String()
+}
+
+// writeMemory обрабатывает M адрес,длина:байты
+func (s *Server) writeMemory(args string) string {
+	rangeText, dataText, ok := strings.Cut(args, ":")
+	if !ok {
+		return "E01"
+	}
+	addr, length, err := parseRange(rangeText)
+	if err != nil {
+		return "E01"
+	}
+	data, err := hex.DecodeString(dataText)
+	if err != nil || uint64(len(data)) != length {
+		return "E01"
+	}
+
+	for i, value := range data {
+		if !s.writeByte(addr+uint64(i), value) {
+			return "E14"
+		}
+	}
+	return "OK"
+}
+
+// readByte читает байт по адресу GDB из памяти данных или кода
+func (s *Server) readByte(addr uint64) (byte, bool) {
+	if addr >= CodeBase {
+		offset := addr - CodeBase
+		if offset >= uint64(len(s.vm.Code)) {
+			return 0, false
+		}
+		return s.vm.Code[offset], true
+	}
+
+	cell, shift := s.dataCell(addr)
+	if cell >= uint64(len(s.vm.Memory)) {
+		return 0, false
+	}
+	return byte(s.vm.Memory[cell] >> shift), true
+}
+
+func (s *Server) writeByte(addr uint64, value byte) bool {
+	if addr >= CodeBase {
+		offset := addr - CodeBase
+		if offset >= uint64(len(s.vm.Code)) {
+			return false
+		}
+		s.vm.Code[offset] = value
+		return true
+	}
+
+	cell, shift := s.dataCell(addr)
+	if cell >= uint64(len(s.vm.Memory)) {
+		return false
+	}
+	if s.vm.Model.Addressing == emulator.ByteAddressing {
+		s.vm.Memory[cell] = uint32(value)
+		return true
+	}
+	// Запись через машину обрезает слово по ширине модели памяти
+	word := s.vm.Memory[cell]&^(0xFF<<shift) | uint32(value)<<shift
+	return s.vm.WriteMemory(uint32(cell), word) == nil
+}
+
+// dataCell возвращает ячейку памяти данных и сдвиг байта в ней для адреса GDB.
+// При адресации по словам слово занимает WordBits/8 байтов.
+func (s *Server) dataCell(addr uint64) (uint64, uint64) {
+	if s.vm.Model.Addressing == emulator.ByteAddressing {
+		return addr, 0
+	}
+	wordBytes := uint64(s.vm.Model.WordBits / 8)
+	return addr / wordBytes, 8 * (addr % wordBytes)
+}
+
+// breakpoint обрабатывает Z/z тип,адрес,размер. Поддерживаются программные
+// (0) и аппаратные (1) точки останова - для эмулятора они одинаковы.
+func (s *Server) breakpoint(insert bool, args string) string {
+	parts := strings.Split(args, ",")
+	if len(parts) < 2 || (parts[0] != "0" && parts[0] != "1") {
+		return ""
+	}
+	addr, err := strconv.ParseUint(parts[1], 16, 64)
+	if err != nil {
+		return "E01"
+	}
+
+	pc := codeAddress(addr)
+	if insert {
+		s.breakpoints[pc] = true
+	} else {
+		delete(s.breakpoints, pc)
+	}
+	return "OK"
+}
+
+// codeAddress переводит адрес в области кода или номер команды в номер команды
+func codeAddress(addr uint64) uint32 {
+	if addr >= CodeBase {
+		return uint32((addr - CodeBase) / assembler.CommandSize)
+	}
+	return uint32(addr)
+}
+
+// resume обрабатывает необязательный адрес продолжения в командах s и c
+func (s *Server) resume(args string) error {
+	if args == "" {
+		return nil
+	}
+	addr, err := strconv.ParseUint(args, 16, 64)
+	if err != nil {
+		return err
+	}
+	s.setPC(codeAddress(addr))
+	return nil
+}
+
+func (s *Server) step(args string) string {
+	if err := s.resume(args); err != nil {
+		return "E01"
+	}
+	if s.vm.Halted {
+		return s.exitReply()
+	}
+	if reply, stopped := s.execute(); stopped {
+		return reply
+	}
+	return stopReply(sigTrap)
+}
+
+// cont выполняет программу до точки останова, ошибки, завершения или прерывания.
+// Первая команда выполняется всегда, чтобы можно было продолжить с точки останова.
+func (s *Server) cont(args string, messages <-chan message) string {
+	if err := s.resume(args); err != nil {
+		return "E01"
+	}
+	for i := 0; ; i++ {
+		if s.vm.Halted {
+			return s.exitReply()
+		}
+		if reply, stopped := s.execute(); stopped {
+			return reply
+		}
+		if s.breakpoints[s.vm.PC] {
+			return "T05swbreak:;"
+		}
+
+		if i%interruptCheckInterval == 0 {
+			select {
+			case msg, ok := <-messages:
+				if !ok || msg.interrupt {
+					return stopReply(sigInt)
+				}
+				// Во время выполнения GDB посылает только прерывание
+				s.logf("пакет во время выполнения пропущен: %s", msg.data)
+			default:
+			}
+		}
+	}
+}
+
+// execute выполняет одну команду. Ошибка выполнения сообщается GDB сигналом
+// (см. faultSignal), машина остается на ошибочной команде.
+func (s *Server) execute() (string, bool) {
+	if err := s.vm.Step(); err != nil {
+		s.logf("ошибка выполнения: %v", err)
+		return stopReply(faultSignal(err)), true
+	}
+	if s.vm.Halted {
+		return s.exitReply(), true
+	}
+	return "", false
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
+
+// parseRange разбирает "адрес,длина" в шестнадцатеричной записи
+func parseRange(text string) (uint64, uint64, error) {
+	addrText, lengthText, ok := strings.Cut(text, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("ожидается адрес,длина: %s", text)
+	}
+	addr, err := strconv.ParseUint(addrText, 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(lengthText, 16, 64)
+	return addr, length, err
+}
+
+// encodeWord записывает значение регистра в порядке байтов цели (little-endian)
+func encodeWord(value uint32) string {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, value)
+	return hex.EncodeToString(buf)
+}
+
+func decodeWord(text string) (uint32, error) {
+	buf, err := hex.DecodeString(text)
+	if err != nil || len(buf) != 4 {
+		return 0, fmt.Errorf("неверное значение регистра: %s", text)
+	}
+	return binary.LittleEndian.Uint32(buf), nil
+}
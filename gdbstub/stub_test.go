@@ -0,0 +1,80 @@
+package gdbstub
+
+import (
+	"testing"
+	"uvm-assembler/assembler"
+	"uvm-assembler/emulator"
+)
+
+func newTestServer(t *testing.T, source string, model emulator.MemoryModel) *Server {
+	t.Helper()
+	program, err := assembler.NewParser(source).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := program.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := emulator.NewWithModel(img, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(vm)
+}
+
+// TestMemoryWordWidth проверяет, что байты GDB отображаются на слова памяти
+// по ширине слова модели, а запись не выходит за нее
+func TestMemoryWordWidth(t *testing.T) {
+	for _, tc := range []struct {
+		bits  uint
+		words []uint32 // ячейки после записи байтов 33 22 11 44 с адреса 0
+	}{
+		{32, []uint32{0x44112233, 0}},
+		{16, []uint32{0x2233, 0x4411}},
+		{8, []uint32{0x33, 0x22, 0x11, 0x44}},
+	} {
+		model := emulator.DefaultMemoryModel()
+		model.Size, model.WordBits = 8, tc.bits
+		s := newTestServer(t, "LOAD R1 1\n", model)
+
+		if reply := s.writeMemory("0,4:33221144"); reply != "OK" {
+			t.Fatalf("%d бит: M0,4 = %s", tc.bits, reply)
+		}
+		for i, want := range tc.words {
+			if got := s.vm.Memory[i]; got != want {
+				t.Errorf("%d бит: ячейка %d = 0x%X, ожидалось 0x%X", tc.bits, i, got, want)
+			}
+		}
+		if reply := s.readMemory("0,4"); reply != "33221144" {
+			t.Errorf("%d бит: m0,4 = %s, ожидалось 33221144", tc.bits, reply)
+		}
+	}
+}
+
+// TestPCCodeAddress проверяет, что pc - адрес в области кода, совпадающий
+// с адресом точки останова
+func TestPCCodeAddress(t *testing.T) {
+	s := newTestServer(t, "LOAD R1 1\nLOAD R2 2\nLOAD R3 3\nLOAD R4 4\n", emulator.DefaultMemoryModel())
+
+	if reply := s.breakpoint(true, "0,8000000f,1"); reply != "OK" {
+		t.Fatalf("Z0 = %s", reply)
+	}
+	if reply := s.cont("", nil); reply != "T05swbreak:;" {
+		t.Fatalf("c = %s", reply)
+	}
+	if reply := s.readRegister("40"); reply != encodeWord(0x8000000f) {
+		t.Errorf("p40 = %s, ожидалось %s", reply, encodeWord(0x8000000f))
+	}
+	if reply := s.readMemory("8000000f,1"); reply != "3b" {
+		t.Errorf("первый байт команды по pc = %s, ожидалось 3b (LOAD R4 4)", reply)
+	}
+
+	// pc записывается адресом в области кода или номером команды
+	if reply := s.writeRegister("40=" + encodeWord(0x80000005)); reply != "OK" || s.vm.PC != 1 {
+		t.Errorf("P40 адресом: %s, PC = %d", reply, s.vm.PC)
+	}
+	if reply := s.writeRegister("40=" + encodeWord(2)); reply != "OK" || s.vm.PC != 2 {
+		t.Errorf("P40 номером: %s, PC = %d", reply, s.vm.PC)
+	}
+}
@@ -0,0 +1,40 @@
+package gdbstub
+
+import (
+	"fmt"
+	"strings"
+	"uvm-assembler/emulator"
+)
+
+// Адресное пространство, которое видит GDB. У УВМ раздельные память команд и данных,
+// поэтому они отображаются в разные области байтовых адресов:
+//
+//	0x00000000 + N*адрес   память данных (слова little-endian, N = WordBits/8)
+//	0x80000000 + 5*адрес   код программы (5-байтовые команды)
+//
+// При адресации памяти по байтам адрес GDB в области данных совпадает с адресом УВМ.
+//
+// Регистр pc содержит адрес текущей команды в области кода (CodeBase + 5*номер),
+// чтобы GDB сопоставлял его с точками останова и читал по нему команду (x/i $pc).
+// Точки останова, адрес продолжения и запись pc принимаются как адресом в области
+// кода, так и номером команды.
+const CodeBase = 0x80000000
+
+// pcRegister - номер регистра pc в описании цели (после R0..R63)
+const pcRegister = emulator.RegisterCount
+
+// targetXML возвращает описание цели для GDB: 64 регистра общего назначения и pc
+func targetXML() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.uvm.core">
+`)
+	for i := 0; i < emulator.RegisterCount; i++ {
+		fmt.Fprintf(&b, "    <reg name=\"r%d\" bitsize=\"32\" type=\"uint32\" regnum=\"%d\"/>\n", i, i)
+	}
+	fmt.Fprintf(&b, "    <reg name=\"pc\" bitsize=\"32\" type=\"code_ptr\" regnum=\"%d\"/>\n", pcRegister)
+	b.WriteString("  </feature>\n</target>\n")
+	return b.String()
+}
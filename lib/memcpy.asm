@@ -0,0 +1,25 @@
+; =============================================
+; БИБЛИОТЕКА УВМ: копирование памяти через READ/WRITE
+; =============================================
+; memcpy4: копирует четыре слова, начиная с адреса в R60,
+; в буфер memcpy_dst.
+; Использует R62 и R63.
+
+.global memcpy4, memcpy_dst
+
+memcpy4:
+    READ R62 0 R60
+    LOAD R63 memcpy_dst
+    WRITE R62 R63
+    READ R62 1 R60
+    LOAD R63 memcpy_dst+1
+    WRITE R62 R63
+    READ R62 2 R60
+    LOAD R63 memcpy_dst+2
+    WRITE R62 R63
+    READ R62 3 R60
+    LOAD R63 memcpy_dst+3
+    WRITE R62 R63
+
+.data
+memcpy_dst: .space 4
@@ -0,0 +1,21 @@
+; =============================================
+; БИБЛИОТЕКА УВМ: векторный квадратный корень
+; =============================================
+; vsqrt4: вычисляет sqrt() четырех слов, начиная с адреса в R60,
+; и записывает результаты в буфер vsqrt_out.
+; Использует R62.
+
+.global vsqrt4, vsqrt_out
+
+vsqrt4:
+    READ R62 0 R60
+    SQRT R62 vsqrt_out
+    READ R62 1 R60
+    SQRT R62 vsqrt_out+1
+    READ R62 2 R60
+    SQRT R62 vsqrt_out+2
+    READ R62 3 R60
+    SQRT R62 vsqrt_out+3
+
+.data
+vsqrt_out: .space 4
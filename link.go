@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"uvm-assembler/assembler"
+	"uvm-assembler/linker"
+)
+
+// writeObject записывает разобранную программу в объектный файл
+func writeObject(program *assembler.Program, inputFile, outputFile string) {
+	obj, err := program.Object(filepath.Base(inputFile))
+	if err != nil {
+		fmt.Printf("❌ Ошибка кодирования: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(outputFile, assembler.MarshalObject(obj), 0644); err != nil {
+		fmt.Printf("❌ Ошибка записи файла: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("📦 Объектный файл записан: %d команд, %d слов данных, %d экспортов, %d импортов, %d перемещений\n",
+		len(obj.Text)/assembler.CommandSize, len(obj.Data), len(obj.Exports()), len(obj.Imports), len(obj.Relocs))
+}
+
+// runLink компонует объектные файлы в программу
+func runLink(args []string) {
+	fs := flag.NewFlagSet("link", flag.ExitOnError)
+	outputFile := fs.String("output", "", "Путь к двоичному файлу-результату")
+	entry := fs.String("entry", "", "Символ точки входа (по умолчанию "+linker.DefaultEntry+", если определен)")
+	dataBase := fs.Uint("data-base", 0, "Адрес памяти, с которого размещаются данные")
+	mapFile := fs.String("map", "", "Путь к текстовой карте компоновки")
+	symbolsFile := fs.String("symbols", "", "Путь к файлу символов (JSON) для дизассемблера и эмулятора")
+	debugInfo := fs.Bool("g", false, "Записать отладочную информацию (адрес -> файл, строка, столбец) в <output>"+assembler.DebugInfoExt)
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler link -output program.bin a.o b.o ... [lib.a ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *outputFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	l := linker.New(linker.Options{Entry: *entry, DataBase: uint32(*dataBase)})
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("❌ Ошибка чтения файла: %v\n", err)
+			os.Exit(1)
+		}
+
+		if assembler.IsArchive(data) {
+			archive, err := assembler.UnmarshalArchive(data)
+			if err != nil {
+				fmt.Printf("❌ %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			l.AddArchive(archive)
+			continue
+		}
+
+		obj, err := assembler.UnmarshalObject(data)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		l.Add(obj)
+	}
+
+	img, err := l.Link()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(*outputFile, assembler.MarshalContainer(img), 0644); err != nil {
+		fmt.Printf("❌ Ошибка записи файла: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Скомпоновано %d объектов: %d команд, точка входа %d\n",
+		len(l.Objects()), len(img.Code())/assembler.CommandSize, img.Entry)
+	for _, name := range l.Objects() {
+		fmt.Printf("  %s\n", name)
+	}
+
+	linkMap := l.Map()
+	if *mapFile != "" {
+		f, err := os.Create(*mapFile)
+		if err == nil {
+			err = linkMap.WriteText(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Printf("❌ Ошибка записи карты компоновки: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🗺️  Карта компоновки: %s\n", *mapFile)
+	}
+
+	if *symbolsFile != "" {
+		if err := linkMap.SymbolTable().Save(*symbolsFile); err != nil {
+			fmt.Printf("❌ Ошибка записи файла символов: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🏷️  Файл символов: %s\n", *symbolsFile)
+	}
+
+	if *debugInfo {
+		writeDebugInfo(l.DebugInfo(), *outputFile)
+	}
+}
+
+// writeDebugInfo записывает отладочную информацию рядом с двоичным файлом
+func writeDebugInfo(info *assembler.DebugInfo, outputFile string) {
+	path := assembler.DebugInfoPath(outputFile)
+	if err := info.Save(path); err != nil {
+		fmt.Printf("❌ Ошибка записи отладочной информации: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🐞 Отладочная информация: %s (%d строк)\n", path, len(info.Lines))
+}
+
+// runArchive собирает объектные файлы в библиотеку
+func runArchive(args []string) {
+	fs := flag.NewFlagSet("ar", flag.ExitOnError)
+	outputFile := fs.String("output", "", "Путь к файлу библиотеки")
+	list := fs.Bool("list", false, "Вывести члены и индекс символов существующей библиотеки")
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler ar -output lib.a a.o b.o ...")
+		fmt.Println("               uvm-assembler ar -list lib.a")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *list {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			os.Exit(1)
+		}
+		listArchive(fs.Arg(0))
+		return
+	}
+
+	if *outputFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var members []*assembler.Object
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("❌ Ошибка чтения файла: %v\n", err)
+			os.Exit(1)
+		}
+
+		obj, err := assembler.UnmarshalObject(data)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		members = append(members, obj)
+	}
+
+	archive, err := assembler.NewArchive(members)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(*outputFile, assembler.MarshalArchive(archive), 0644); err != nil {
+		fmt.Printf("❌ Ошибка записи файла: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("📚 Библиотека записана: %d объектов, %d символов\n", len(members), len(archive.Index))
+}
+
+// listArchive выводит члены библиотеки и ее индекс символов
+func listArchive(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("❌ Ошибка чтения файла: %v\n", err)
+		os.Exit(1)
+	}
+
+	archive, err := assembler.UnmarshalArchive(data)
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Члены библиотеки:")
+	for i, obj := range archive.Members {
+		fmt.Printf("  %d: %s (%d команд, %d слов данных)\n",
+			i, obj.Name, len(obj.Text)/assembler.CommandSize, len(obj.Data))
+	}
+	fmt.Println("Индекс символов:")
+	for _, name := range archive.Symbols() {
+		fmt.Printf("  %-20s %s\n", name, archive.Members[archive.Index[name]].Name)
+	}
+}
@@ -0,0 +1,285 @@
+package linker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"uvm-assembler/assembler"
+)
+
+// DefaultEntry - символ точки входа, если она не задана явно
+const DefaultEntry = "_start"
+
+// Options - параметры компоновки
+type Options struct {
+	Entry    string // символ точки входа; по умолчанию _start, если он определен
+	DataBase uint32 // адрес, с которого размещаются данные всех объектов
+}
+
+// placement - расположение секций объекта в итоговой программе
+type placement struct {
+	obj      *assembler.Object
+	textBase uint32 // номер первой команды объекта
+	dataBase uint32 // адрес первого слова данных объекта
+	dataFrom int    // индекс первого слова данных объекта в итоговой секции
+}
+
+// Linker объединяет объектные файлы в одну программу
+type Linker struct {
+	options    Options
+	placements []*placement
+	archives   []*assembler.Archive
+	globals    map[string]resolved
+}
+
+// resolved - символ с окончательным значением
+type resolved struct {
+	value   uint32
+	section assembler.SectionKind
+	object  string
+}
+
+// Error перечисляет все проблемы, найденные при компоновке
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "ошибки компоновки:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// New создает компоновщик
+func New(options Options) *Linker {
+	return &Linker{options: options}
+}
+
+// Add добавляет объектный файл; объекты размещаются в порядке добавления
+func (l *Linker) Add(obj *assembler.Object) {
+	l.placements = append(l.placements, &placement{obj: obj})
+}
+
+// AddArchive добавляет библиотеку. Из нее берутся только те члены, которые
+// определяют еще неразрешенные символы.
+func (l *Linker) AddArchive(a *assembler.Archive) {
+	l.archives = append(l.archives, a)
+}
+
+// Objects возвращает имена объектов в порядке размещения, включая взятые из библиотек
+func (l *Linker) Objects() []string {
+	var result []string
+	for _, pl := range l.placements {
+		result = append(result, pl.obj.Name)
+	}
+	return result
+}
+
+// Link размещает секции, разрешает символы и применяет перемещения
+func (l *Linker) Link() (*assembler.Image, error) {
+	l.pullMembers()
+
+	var problems []string
+	var text []byte
+	var data []uint32
+
+	for _, pl := range l.placements {
+		pl.textBase = uint32(len(text) / assembler.CommandSize)
+		pl.dataBase = l.options.DataBase + uint32(len(data))
+		pl.dataFrom = len(data)
+		text = append(text, pl.obj.Text...)
+		data = append(data, pl.obj.Data...)
+	}
+
+	l.globals = make(map[string]resolved)
+	for _, pl := range l.placements {
+		for _, sym := range pl.obj.Exports() {
+			if old, exists := l.globals[sym.Name]; exists {
+				problems = append(problems, fmt.Sprintf("символ %s определен повторно: в %s и в %s", sym.Name, old.object, pl.obj.Name))
+				continue
+			}
+			l.globals[sym.Name] = pl.resolve(sym)
+		}
+	}
+
+	for _, pl := range l.placements {
+		for _, rel := range pl.obj.Relocs {
+			sym, ok := l.lookup(pl, rel.Symbol)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("неразрешенный символ %s (%s, строка %d)", rel.Symbol, pl.obj.Name, rel.Line))
+				continue
+			}
+
+			value, err := assembler.ApplyAddend(sym.value, rel.Addend)
+			if err == nil {
+				err = pl.apply(text, data, rel, value)
+			}
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s, строка %d: %s: %v", pl.obj.Name, rel.Line, rel.Symbol, err))
+			}
+		}
+	}
+
+	entry, err := l.entry()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	img := assembler.NewImage(text)
+	img.Entry = entry
+	if len(data) > 0 {
+		img.Sections = append(img.Sections, assembler.Section{
+			Kind: assembler.SectionData,
+			Addr: l.options.DataBase,
+			Data: assembler.EncodeWords(data),
+		})
+	}
+	return img, nil
+}
+
+// pullMembers добавляет члены библиотек, пока они разрешают новые символы
+func (l *Linker) pullMembers() {
+	taken := make(map[*assembler.Object]bool)
+	for {
+		added := false
+		for _, name := range l.undefined() {
+			for _, a := range l.archives {
+				i, ok := a.Index[name]
+				if !ok {
+					continue
+				}
+				if member := a.Members[i]; !taken[member] {
+					taken[member] = true
+					l.Add(member)
+					added = true
+				}
+				break
+			}
+		}
+		if !added {
+			return
+		}
+	}
+}
+
+// undefined возвращает символы, на которые ссылаются объекты, но которые никто не экспортирует
+func (l *Linker) undefined() []string {
+	defined := make(map[string]bool)
+	for _, pl := range l.placements {
+		for _, sym := range pl.obj.Exports() {
+			defined[sym.Name] = true
+		}
+	}
+
+	missing := make(map[string]bool)
+	for _, pl := range l.placements {
+		for _, rel := range pl.obj.Relocs {
+			if _, local := pl.local(rel.Symbol); !local && !defined[rel.Symbol] {
+				missing[rel.Symbol] = true
+			}
+		}
+	}
+	if l.options.Entry != "" && !defined[l.options.Entry] {
+		missing[l.options.Entry] = true
+	}
+
+	result := make([]string, 0, len(missing))
+	for name := range missing {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// DebugInfo возвращает таблицу строк скомпонованной программы
+func (l *Linker) DebugInfo() *assembler.DebugInfo {
+	var lines []assembler.LineEntry
+	for _, pl := range l.placements {
+		for _, entry := range pl.obj.Lines {
+			entry.Address += pl.textBase
+			lines = append(lines, entry)
+		}
+	}
+	return assembler.NewDebugInfo(lines)
+}
+
+// lookup ищет символ сначала среди символов объекта, затем среди глобальных
+func (l *Linker) lookup(pl *placement, name string) (resolved, bool) {
+	if sym, ok := pl.local(name); ok {
+		return pl.resolve(sym), true
+	}
+	sym, ok := l.globals[name]
+	return sym, ok
+}
+
+// local ищет символ, определенный в самом объекте
+func (pl *placement) local(name string) (assembler.ObjectSymbol, bool) {
+	for _, sym := range pl.obj.Symbols {
+		if sym.Name == name {
+			return sym, true
+		}
+	}
+	return assembler.ObjectSymbol{}, false
+}
+
+// entryName возвращает имя символа точки входа
+func (l *Linker) entryName() string {
+	if l.options.Entry != "" {
+		return l.options.Entry
+	}
+	return DefaultEntry
+}
+
+// entry определяет точку входа программы
+func (l *Linker) entry() (uint32, error) {
+	name := l.entryName()
+	sym, ok := l.globals[name]
+	if !ok {
+		if l.options.Entry != "" {
+			return 0, fmt.Errorf("символ точки входа %s не найден среди глобальных символов", name)
+		}
+		return 0, nil
+	}
+	if sym.section != assembler.SectionCode {
+		return 0, fmt.Errorf("точка входа %s не является меткой кода", name)
+	}
+	return sym.value, nil
+}
+
+// resolve переводит значение символа объекта в итоговый адрес
+func (pl *placement) resolve(sym assembler.ObjectSymbol) resolved {
+	value := sym.Value
+	switch sym.Section {
+	case assembler.SectionCode:
+		value += pl.textBase
+	case assembler.SectionData:
+		value += pl.dataBase
+	}
+	return resolved{value: value, section: sym.Section, object: pl.obj.Name}
+}
+
+// apply записывает значение в поле команды или слово данных
+func (pl *placement) apply(text []byte, data []uint32, rel assembler.Relocation, value uint32) error {
+	switch rel.Section {
+	case assembler.SectionCode:
+		if int(rel.Offset) >= len(pl.obj.Text)/assembler.CommandSize {
+			return fmt.Errorf("перемещение за пределами кода объекта")
+		}
+		start := int(pl.textBase+rel.Offset) * assembler.CommandSize
+		field := assembler.FieldLayout{Field: assembler.FieldNone, Offset: uint(rel.BitOffset), Width: uint(rel.Width)}
+		if value > field.Max() {
+			return fmt.Errorf("значение %d не помещается в поле %s (%d бит)", value, rel.Symbol, field.Width)
+		}
+		return assembler.PatchField(text[start:start+assembler.CommandSize], field, value)
+	case assembler.SectionData:
+		if int(rel.Offset) >= len(pl.obj.Data) {
+			return fmt.Errorf("перемещение за пределами данных объекта")
+		}
+		data[pl.dataFrom+int(rel.Offset)] = value
+		return nil
+	default:
+		return fmt.Errorf("неизвестная секция перемещения: %v", rel.Section)
+	}
+}
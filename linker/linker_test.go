@@ -0,0 +1,204 @@
+package linker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"uvm-assembler/assembler"
+)
+
+// object собирает объектный файл и пропускает его через MarshalObject/UnmarshalObject
+func object(t *testing.T, name, source string) *assembler.Object {
+	t.Helper()
+	program, err := assembler.NewParser(source).ParseProgram()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	obj, err := program.Object(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	read, err := assembler.UnmarshalObject(assembler.MarshalObject(obj))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return read
+}
+
+// field читает поле команды с номером index из скомпонованного кода
+func field(t *testing.T, code []byte, index int, f assembler.Field) uint32 {
+	t.Helper()
+	cmd := code[index*assembler.CommandSize : (index+1)*assembler.CommandSize]
+	opcode := assembler.ExtractField(cmd, assembler.OpcodeField)
+	layout, ok := assembler.FieldOf(assembler.CommandType(opcode), f)
+	if !ok {
+		t.Fatalf("команда %d (код %d) не имеет поля %v", index, opcode, f)
+	}
+	return assembler.ExtractField(cmd, layout)
+}
+
+const tableSource = `
+.global table
+.data
+.word 1
+table: .word 5
+`
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		options Options
+		check   func(t *testing.T, img *assembler.Image)
+		wantErr string
+	}{
+		{
+			name: "поле C (24 бита) с добавкой",
+			sources: []string{
+				".extern table\nLOAD R1 table+2\nSQRT R2 table\n",
+				tableSource,
+			},
+			options: Options{DataBase: 100},
+			check: func(t *testing.T, img *assembler.Image) {
+				if got := field(t, img.Code(), 0, assembler.FieldC); got != 103 {
+					t.Errorf("LOAD C = %d, ожидалось 103", got)
+				}
+				if got := field(t, img.Code(), 1, assembler.FieldC); got != 101 {
+					t.Errorf("SQRT C = %d, ожидалось 101", got)
+				}
+				if got := field(t, img.Code(), 1, assembler.FieldB); got != 2 {
+					t.Errorf("SQRT B = %d, ожидалось 2: перемещение испортило соседнее поле", got)
+				}
+			},
+		},
+		{
+			name: "поле B (16 бит)",
+			sources: []string{
+				".extern table\nREAD R3 table R4\n",
+				tableSource,
+			},
+			options: Options{DataBase: 0xFFFE},
+			check: func(t *testing.T, img *assembler.Image) {
+				if got := field(t, img.Code(), 0, assembler.FieldB); got != 0xFFFF {
+					t.Errorf("READ B = %d, ожидалось %d", got, 0xFFFF)
+				}
+				if c, d := field(t, img.Code(), 0, assembler.FieldC), field(t, img.Code(), 0, assembler.FieldD); c != 4 || d != 3 {
+					t.Errorf("READ C, D = %d, %d, ожидалось 4, 3", c, d)
+				}
+			},
+		},
+		{
+			name: "размещение кода, данных и точка входа _start",
+			sources: []string{
+				".extern start_data\nLOAD R1 start_data\n.data\n.word 9, 9\n",
+				".global _start\n.global start_data\n_start:\nLOAD R2 _start\n.data\nstart_data: .word _start\n",
+			},
+			options: Options{DataBase: 10},
+			check: func(t *testing.T, img *assembler.Image) {
+				if img.Entry != 1 {
+					t.Errorf("точка входа %d, ожидалась 1", img.Entry)
+				}
+				if got := field(t, img.Code(), 0, assembler.FieldC); got != 12 {
+					t.Errorf("start_data = %d, ожидалось 12", got)
+				}
+				if got := field(t, img.Code(), 1, assembler.FieldC); got != 1 {
+					t.Errorf("_start = %d, ожидалось 1", got)
+				}
+				sections := img.DataSections()
+				if len(sections) != 1 || sections[0].Addr != 10 {
+					t.Fatalf("секции данных %+v, ожидалась одна по адресу 10", sections)
+				}
+				if got, want := assembler.DecodeWords(sections[0].Data), []uint32{9, 9, 1}; !reflect.DeepEqual(got, want) {
+					t.Errorf("данные %v, ожидалось %v", got, want)
+				}
+			},
+		},
+		{
+			name: "переполнение поля символ+добавка",
+			sources: []string{
+				".extern table\nREAD R3 table+1 R4\n",
+				tableSource,
+			},
+			options: Options{DataBase: 0xFFFE},
+			wantErr: "не помещается в поле table (16 бит)",
+		},
+		{
+			name: "отрицательный результат символ+добавка",
+			sources: []string{
+				".extern table\nLOAD R1 table-5\n",
+				tableSource,
+			},
+			wantErr: "выходит за пределы 32 бит",
+		},
+		{
+			name: "повторный глобальный символ",
+			sources: []string{
+				".global f\nf:\nLOAD R1 1\n",
+				".global f\nf:\nLOAD R2 2\n",
+			},
+			wantErr: "символ f определен повторно: в obj0.o и в obj1.o",
+		},
+		{
+			name:    "неразрешенный внешний символ",
+			sources: []string{".extern missing\nLOAD R1 missing\n"},
+			wantErr: "неразрешенный символ missing (obj0.o, строка 2)",
+		},
+		{
+			name:    "нет символа -entry",
+			sources: []string{".global _start\n_start:\nLOAD R1 1\n"},
+			options: Options{Entry: "main"},
+			wantErr: "символ точки входа main не найден",
+		},
+		{
+			name:    "точка входа - не метка кода",
+			sources: []string{".global _start\n.data\n_start: .word 0\n"},
+			wantErr: "точка входа _start не является меткой кода",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.options)
+			for i, source := range tt.sources {
+				l.Add(object(t, fmt.Sprintf("obj%d.o", i), source))
+			}
+			img, err := l.Link()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, img)
+		})
+	}
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	program, err := assembler.NewParser(".global _start\n.extern table\n_start:\nLOAD R1 table+3\n.data\n.word _start\n").ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := program.Object("a.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := assembler.MarshalObject(obj)
+
+	read, err := assembler.UnmarshalObject(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, obj) {
+		t.Errorf("после чтения %+v, ожидалось %+v", read, obj)
+	}
+
+	data[len(data)/2] ^= 0xFF
+	if _, err := assembler.UnmarshalObject(data); err == nil || !strings.Contains(err.Error(), "контрольная сумма") {
+		t.Errorf("поврежденный файл: ошибка %v, ожидалась ошибка контрольной суммы", err)
+	}
+}
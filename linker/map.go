@@ -0,0 +1,117 @@
+package linker
+
+import (
+	"fmt"
+	"io"
+	"uvm-assembler/assembler"
+)
+
+// MapSection - размещение секции одного объекта
+type MapSection struct {
+	Object string
+	Kind   assembler.SectionKind
+	Addr   uint32
+	Size   uint32 // в командах или словах данных
+}
+
+// Map - карта компоновки: где оказались секции и символы
+type Map struct {
+	Entry    uint32
+	Sections []MapSection
+	Symbols  []assembler.SymbolEntry
+}
+
+// Map возвращает карту последней успешной компоновки
+func (l *Linker) Map() *Map {
+	m := &Map{}
+	if sym, ok := l.globals[l.entryName()]; ok && sym.section == assembler.SectionCode {
+		m.Entry = sym.value
+	}
+
+	for _, pl := range l.placements {
+		textSize := uint32(len(pl.obj.Text) / assembler.CommandSize)
+		dataSize := uint32(len(pl.obj.Data))
+		if textSize > 0 {
+			m.Sections = append(m.Sections, MapSection{pl.obj.Name, assembler.SectionCode, pl.textBase, textSize})
+		}
+		if dataSize > 0 {
+			m.Sections = append(m.Sections, MapSection{pl.obj.Name, assembler.SectionData, pl.dataBase, dataSize})
+		}
+
+		sizes := symbolSizes(pl.obj.Symbols, map[assembler.SectionKind]uint32{
+			assembler.SectionCode: textSize,
+			assembler.SectionData: dataSize,
+		})
+		for i, sym := range pl.obj.Symbols {
+			r := pl.resolve(sym)
+			m.Symbols = append(m.Symbols, assembler.SymbolEntry{
+				Name:    sym.Name,
+				Section: sym.Section.String(),
+				Address: r.value,
+				Size:    sizes[i],
+				Global:  sym.Global,
+				Object:  pl.obj.Name,
+			})
+		}
+	}
+
+	assembler.SortSymbols(m.Symbols)
+	return m
+}
+
+// SymbolTable возвращает машиночитаемую таблицу символов
+func (m *Map) SymbolTable() *assembler.SymbolTable {
+	return &assembler.SymbolTable{
+		Version: assembler.SymbolFileVersion,
+		Entry:   m.Entry,
+		Symbols: m.Symbols,
+	}
+}
+
+// WriteText записывает карту компоновки в текстовом виде
+func (m *Map) WriteText(w io.Writer) error {
+	fmt.Fprintln(w, "КАРТА КОМПОНОВКИ УВМ")
+	fmt.Fprintf(w, "Точка входа: %d\n\n", m.Entry)
+
+	fmt.Fprintln(w, "Секции:")
+	fmt.Fprintf(w, "  %-6s %-10s %-10s %s\n", "Вид", "Адрес", "Размер", "Объект")
+	for _, s := range m.Sections {
+		fmt.Fprintf(w, "  %-6s %-10d %-10d %s\n", s.Kind, s.Addr, s.Size, s.Object)
+	}
+
+	fmt.Fprintln(w, "\nСимволы:")
+	fmt.Fprintf(w, "  %-6s %-10s %-10s %-8s %-24s %s\n", "Вид", "Адрес", "Размер", "Область", "Имя", "Объект")
+	for _, sym := range m.Symbols {
+		scope := "local"
+		if sym.Global {
+			scope = "global"
+		}
+		_, err := fmt.Fprintf(w, "  %-6s %-10d %-10d %-8s %-24s %s\n",
+			sym.Section, sym.Address, sym.Size, scope, sym.Name, sym.Object)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// symbolSizes вычисляет размер каждого символа как расстояние до следующего
+// символа той же секции или до конца секции объекта
+func symbolSizes(symbols []assembler.ObjectSymbol, sectionSize map[assembler.SectionKind]uint32) []uint32 {
+	sizes := make([]uint32, len(symbols))
+	for i, sym := range symbols {
+		end, ok := sectionSize[sym.Section]
+		if !ok {
+			continue
+		}
+		for _, other := range symbols {
+			if other.Section == sym.Section && other.Value > sym.Value && other.Value < end {
+				end = other.Value
+			}
+		}
+		if end > sym.Value {
+			sizes[i] = end - sym.Value
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,319 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"uvm-assembler/assembler"
+)
+
+// mnemonic - описание команды для автодополнения и подсказок
+type mnemonic struct {
+	name      string
+	syntax    string
+	semantics string
+	typ       assembler.CommandType
+}
+
+var mnemonics = []mnemonic{
+	{"LOAD", "LOAD <регистр> <константа>", "R[B] = C", assembler.LOAD_CONST},
+	{"READ", "READ <регистр_результата> <смещение> <базовый_регистр>", "R[D] = mem[R[C] + B]", assembler.READ_MEM},
+	{"WRITE", "WRITE <регистр_значения> <регистр_адреса>", "mem[R[C]] = R[B]", assembler.WRITE_MEM},
+	{"SQRT", "SQRT <регистр_источника> <адрес_результата>", "mem[C] = sqrt(R[B])", assembler.SQRT_OP},
+}
+
+var directives = []struct{ name, doc string }{
+	{".text", "секция кода"},
+	{".data", "секция начальных данных"},
+	{".global", "сделать символ видимым другим объектным файлам"},
+	{".extern", "объявить символ, определенный в другом файле"},
+	{".equ", "константа: .equ ИМЯ, значение"},
+	{".word", "слова данных: .word 1, 2, метка"},
+	{".space", "зарезервировать слова, заполненные нулями"},
+}
+
+// document - открытый в редакторе исходный файл
+type document struct {
+	uri   string
+	path  string
+	lines []string
+}
+
+func newDocument(uri, text string) *document {
+	return &document{uri: uri, path: uriPath(uri), lines: strings.Split(text, "\n")}
+}
+
+// uriPath переводит file:// URI в путь файла
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// analyze разбирает документ, не останавливаясь на первой ошибке
+func (d *document) analyze() (*assembler.Program, []*assembler.SourceError) {
+	parser := assembler.NewParser(strings.Join(d.lines, "\n"))
+	parser.SetFilename(d.path)
+	return parser.Check()
+}
+
+// diagnostics возвращает ошибки разбора, а если их нет - ошибки кодирования
+func (d *document) diagnostics() []Diagnostic {
+	program, errs := d.analyze()
+	if len(errs) == 0 {
+		if err := encode(program, d.path); err != nil {
+			var srcErr *assembler.SourceError
+			if !errors.As(err, &srcErr) {
+				srcErr = &assembler.SourceError{Err: err}
+			}
+			errs = append(errs, srcErr)
+		}
+	}
+
+	result := []Diagnostic{}
+	for _, e := range errs {
+		result = append(result, Diagnostic{
+			Range:    d.lineRange(e.Line, e.Column),
+			Severity: severityError,
+			Source:   "uvm",
+			Message:  e.Err.Error(),
+		})
+	}
+	return result
+}
+
+// encode кодирует программу так, как ее собрал бы ассемблер: файл с внешними
+// символами - объектный (-object), остальные - готовая программа
+func encode(program *assembler.Program, path string) error {
+	for _, sym := range program.Symbols {
+		if sym.Extern {
+			_, err := program.Object(filepath.Base(path))
+			return err
+		}
+	}
+	_, err := program.Image()
+	return err
+}
+
+// lineRange возвращает диапазон от колонки column (с 1) до конца строки line (с 1)
+func (d *document) lineRange(line, column int) Range {
+	if line <= 0 || line > len(d.lines) {
+		return Range{}
+	}
+	text := d.lines[line-1]
+	start := 0
+	if column > 0 {
+		start = utf16Len(string([]rune(text)[:min(column-1, len([]rune(text)))]))
+	}
+	end := utf16Len(strings.TrimRight(text, "\r"))
+	return Range{Start: Position{line - 1, start}, End: Position{line - 1, max(end, start)}}
+}
+
+// wordAt возвращает слово под курсором и его диапазон
+func (d *document) wordAt(pos Position) (string, Range) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return "", Range{}
+	}
+	runes := []rune(d.lines[pos.Line])
+	idx := runeIndex(runes, pos.Character)
+
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' }
+	start, end := idx, idx
+	for start > 0 && isWord(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isWord(runes[end]) {
+		end++
+	}
+	if start == end {
+		return "", Range{}
+	}
+	return string(runes[start:end]), Range{
+		Start: Position{pos.Line, utf16Len(string(runes[:start]))},
+		End:   Position{pos.Line, utf16Len(string(runes[:end]))},
+	}
+}
+
+// completion предлагает команды и директивы в начале строки, иначе регистры и символы
+func (d *document) completion(pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return items
+	}
+	runes := []rune(d.lines[pos.Line])
+	prefix := string(runes[:runeIndex(runes, pos.Character)])
+	if idx := strings.Index(prefix, ":"); idx >= 0 {
+		prefix = prefix[idx+1:] // после метки
+	}
+	if strings.Contains(prefix, ";") {
+		return items // комментарий
+	}
+
+	fields := strings.Fields(prefix)
+	if len(fields) == 0 || (len(fields) == 1 && !strings.HasSuffix(prefix, " ") && !strings.HasSuffix(prefix, "\t")) {
+		for _, m := range mnemonics {
+			items = append(items, CompletionItem{Label: m.name, Kind: completionKeyword, Detail: m.syntax, Documentation: m.semantics})
+		}
+		for _, dir := range directives {
+			items = append(items, CompletionItem{Label: dir.name, Kind: completionKeyword, Detail: dir.doc})
+		}
+		return items
+	}
+
+	for i := 0; i < 64; i++ {
+		items = append(items, CompletionItem{Label: fmt.Sprintf("R%d", i), Kind: completionVariable, Detail: "регистр"})
+	}
+	if program, _ := d.analyze(); program != nil {
+		for _, sym := range program.SortedSymbols() {
+			kind := completionFunction
+			if sym.Section == assembler.SectionAbsolute {
+				kind = completionConstant
+			}
+			items = append(items, CompletionItem{Label: sym.Name, Kind: kind, Detail: describeSymbol(sym)})
+		}
+	}
+	return items
+}
+
+// hover описывает команду строки: поля кодировки и байты; для символа и регистра -
+// их значение
+func (d *document) hover(pos Position) (string, bool) {
+	program, _ := d.analyze()
+	word, _ := d.wordAt(pos)
+
+	if program != nil && word != "" {
+		if sym, ok := program.Symbols[word]; ok {
+			return fmt.Sprintf("**%s** - %s", sym.Name, describeSymbol(sym)), true
+		}
+	}
+
+	line := pos.Line + 1
+	if program != nil {
+		for _, cmd := range program.Commands {
+			if cmd.Line == line {
+				return describeCommand(resolveCommand(program, cmd)), true
+			}
+		}
+	}
+
+	if reg := strings.ToUpper(word); len(reg) > 1 && reg[0] == 'R' && strings.Trim(reg[1:], "0123456789") == "" {
+		return fmt.Sprintf("Регистр **%s** (32 бита)", reg), true
+	}
+	return "", false
+}
+
+// resolveCommand подставляет в команду значение символа, определенного в этом файле.
+// Команда с внешним символом остается с незаполненным полем.
+func resolveCommand(program *assembler.Program, cmd assembler.Command) assembler.Command {
+	if cmd.Ref == nil {
+		return cmd
+	}
+	sym, ok := program.Symbols[cmd.Ref.Symbol]
+	if !ok || sym.Extern || sym.Line == 0 {
+		return cmd
+	}
+	value, err := assembler.ApplyAddend(sym.Value, cmd.Ref.Addend)
+	if err != nil {
+		return cmd
+	}
+
+	cmd.Fields[cmd.Ref.Field] = value
+	cmd.Ref = nil
+	return cmd
+}
+
+// describeCommand возвращает описание кодировки команды в Markdown
+func describeCommand(cmd assembler.Command) string {
+	var b strings.Builder
+	for _, m := range mnemonics {
+		if m.typ == cmd.Type {
+			fmt.Fprintf(&b, "**%s** (код %d): `%s`\n\n`%s`\n\n", m.name, cmd.Type, m.semantics, m.syntax)
+		}
+	}
+
+	layout, _ := assembler.Layout(cmd.Type)
+	b.WriteString("| Поле | Биты | Значение |\n|---|---|---|\n")
+	for _, f := range layout {
+		value := cmd.Fields[f.Field]
+		if f.Field == assembler.FieldA {
+			value = uint32(cmd.Type)
+		}
+		fmt.Fprintf(&b, "| %s | %d-%d | %d |\n", f.Field, f.Offset, f.Offset+f.Width-1, value)
+	}
+
+	if cmd.Ref != nil {
+		fmt.Fprintf(&b, "\nПоле %s задается символом `%s` при компоновке\n", cmd.Ref.Field, cmd.Ref.Symbol)
+	}
+	if code, err := assembler.NewEncoder().Encode(cmd); err == nil {
+		fmt.Fprintf(&b, "\nБайты: `%s`\n", assembler.NewEncoder().BytesToHexString(code))
+	} else {
+		fmt.Fprintf(&b, "\n❌ %v\n", err)
+	}
+	return b.String()
+}
+
+func describeSymbol(sym *assembler.Symbol) string {
+	switch {
+	case sym.Extern:
+		return "внешний символ (.extern)"
+	case sym.Section == assembler.SectionCode:
+		return fmt.Sprintf("метка кода, команда %d (строка %d)", sym.Value, sym.Line)
+	case sym.Section == assembler.SectionData:
+		return fmt.Sprintf("метка данных, адрес %d (строка %d)", sym.Value, sym.Line)
+	default:
+		return fmt.Sprintf("константа .equ = %d (строка %d)", sym.Value, sym.Line)
+	}
+}
+
+// definition находит строку, где определен символ под курсором
+func (d *document) definition(pos Position) (*assembler.Symbol, bool) {
+	word, _ := d.wordAt(pos)
+	program, _ := d.analyze()
+	if word == "" || program == nil {
+		return nil, false
+	}
+	sym, ok := program.Symbols[word]
+	if !ok || sym.Line == 0 {
+		return sym, false
+	}
+	return sym, true
+}
+
+// symbolRange возвращает диапазон имени символа в строке его определения
+func (d *document) symbolRange(sym *assembler.Symbol) Range {
+	if sym.Line <= 0 || sym.Line > len(d.lines) {
+		return Range{}
+	}
+	text := d.lines[sym.Line-1]
+	idx := strings.Index(text, sym.Name)
+	if idx < 0 {
+		return Range{Start: Position{sym.Line - 1, 0}, End: Position{sym.Line - 1, 0}}
+	}
+	start := utf16Len(text[:idx])
+	return Range{
+		Start: Position{sym.Line - 1, start},
+		End:   Position{sym.Line - 1, start + utf16Len(sym.Name)},
+	}
+}
+
+// runeIndex переводит позицию в единицах UTF-16 в индекс руны строки
+func runeIndex(runes []rune, character int) int {
+	units := 0
+	for i, r := range runes {
+		if units >= character {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(runes)
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
@@ -0,0 +1,256 @@
+// Package lsp реализует сервер Language Server Protocol для языка ассемблера УВМ:
+// диагностика при сохранении, автодополнение, подсказки с кодировкой команд
+// и переход к определению символов.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Server - языковой сервер, работающий с одним клиентом
+type Server struct {
+	in  io.Reader
+	out io.Writer
+	Log io.Writer // необязательный журнал сообщений
+
+	documents map[string]*document
+	shutdown  bool
+}
+
+// NewServer создает сервер, читающий сообщения из in и пишущий ответы в out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: in, out: out, documents: map[string]*document{}}
+}
+
+// Run обрабатывает сообщения до уведомления exit или конца ввода
+func (s *Server) Run() error {
+	r := bufio.NewReader(s.in)
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.logf("-> %s %s", msg.Method, msg.Params)
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("получен exit без shutdown")
+			}
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+// handle выполняет запрос или уведомление
+func (s *Server) handle(msg *message) {
+	isRequest := len(msg.ID) > 0
+	var result any
+	var err error
+
+	switch msg.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					"change":    1, // документ передается целиком
+					"save":      map[string]any{"includeText": true},
+				},
+				"completionProvider": map[string]any{"triggerCharacters": []string{" ", "."}},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "uvm-lsp"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		err = s.didOpen(msg.Params)
+	case "textDocument/didChange":
+		err = s.didChange(msg.Params)
+	case "textDocument/didSave":
+		err = s.didSave(msg.Params)
+	case "textDocument/didClose":
+		err = s.didClose(msg.Params)
+	case "textDocument/completion":
+		result, err = s.completion(msg.Params)
+	case "textDocument/hover":
+		result, err = s.hover(msg.Params)
+	case "textDocument/definition":
+		result, err = s.definition(msg.Params)
+	default:
+		if isRequest {
+			s.replyError(msg.ID, codeMethodNotFound, fmt.Errorf("метод не поддерживается: %s", msg.Method))
+		}
+		return
+	}
+
+	switch {
+	case !isRequest:
+		if err != nil {
+			s.logf("ошибка обработки %s: %v", msg.Method, err)
+		}
+	case err != nil:
+		s.replyError(msg.ID, codeInvalidParams, err)
+	default:
+		s.reply(msg.ID, result)
+	}
+}
+
+type textDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// didOpen запоминает документ и сразу показывает его ошибки
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p struct {
+		TextDocument textDocumentItem `json:"textDocument"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	doc := newDocument(p.TextDocument.URI, p.TextDocument.Text)
+	s.documents[doc.uri] = doc
+	s.publish(doc)
+	return nil
+}
+
+// didChange обновляет текст документа; диагностика обновляется при сохранении
+func (s *Server) didChange(params json.RawMessage) error {
+	var p struct {
+		TextDocument   textDocumentItem `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	if n := len(p.ContentChanges); n > 0 {
+		s.documents[p.TextDocument.URI] = newDocument(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+	}
+	return nil
+}
+
+func (s *Server) didSave(params json.RawMessage) error {
+	var p struct {
+		TextDocument textDocumentItem `json:"textDocument"`
+		Text         *string          `json:"text"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	uri := p.TextDocument.URI
+	if p.Text != nil {
+		s.documents[uri] = newDocument(uri, *p.Text)
+	}
+	if doc, ok := s.documents[uri]; ok {
+		s.publish(doc)
+	}
+	return nil
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p struct {
+		TextDocument textDocumentItem `json:"textDocument"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	delete(s.documents, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", map[string]any{"uri": p.TextDocument.URI, "diagnostics": []Diagnostic{}})
+	return nil
+}
+
+// publish отправляет диагностику документа
+func (s *Server) publish(doc *document) {
+	s.notify("textDocument/publishDiagnostics", map[string]any{"uri": doc.uri, "diagnostics": doc.diagnostics()})
+}
+
+// position разбирает параметры запроса с позицией в документе
+func (s *Server) position(params json.RawMessage) (*document, Position, error) {
+	var p textDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, Position{}, err
+	}
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, Position{}, fmt.Errorf("документ не открыт: %s", p.TextDocument.URI)
+	}
+	return doc, p.Position, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	doc, pos, err := s.position(params)
+	if err != nil {
+		return nil, err
+	}
+	return doc.completion(pos), nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	doc, pos, err := s.position(params)
+	if err != nil {
+		return nil, err
+	}
+	text, ok := doc.hover(pos)
+	if !ok {
+		return nil, nil
+	}
+	_, wordRange := doc.wordAt(pos)
+	return map[string]any{
+		"contents": map[string]any{"kind": "markdown", "value": text},
+		"range":    wordRange,
+	}, nil
+}
+
+// definition возвращает место определения метки или константы .equ. Для символа,
+// объявленного .extern, ищется глобальное определение в других открытых документах.
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	doc, pos, err := s.position(params)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, ok := doc.definition(pos)
+	if ok {
+		return []Location{{URI: doc.uri, Range: doc.symbolRange(sym)}}, nil
+	}
+	if sym == nil || !sym.Extern {
+		return nil, nil
+	}
+
+	uris := make([]string, 0, len(s.documents))
+	for uri := range s.documents {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		other := s.documents[uri]
+		program, _ := other.analyze()
+		if program == nil {
+			continue
+		}
+		if def, ok := program.Symbols[sym.Name]; ok && def.Global && def.Line > 0 {
+			return []Location{{URI: other.uri, Range: other.symbolRange(def)}}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
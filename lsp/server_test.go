@@ -0,0 +1,275 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	mainURI = "file:///project/main.asm"
+	libURI  = "file:///project/lib.asm"
+)
+
+// Комментарии с кириллицей и символом вне BMP (две единицы UTF-16)
+const mainSource = `.global _start
+.extern table
+; вход программы 🙂
+_start: LOAD R9 771 ; загрузка 🙂
+    SQRT R9 value
+; 🙂 R5
+.data
+value: .word 0
+`
+
+const libSource = `.global table
+; таблица 🙂
+.data
+table: .word 1, 2
+`
+
+// client передает сообщения серверу через io.Pipe и читает его ответы
+type client struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	server := NewServer(inR, outW)
+	done := make(chan error, 1)
+	go func() { done <- server.Run() }()
+	t.Cleanup(func() {
+		inW.Close()
+		outR.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	c := &client{t: t, in: inW, out: bufio.NewReader(outR)}
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) write(msg map[string]any) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.write(map[string]any{"method": method, "params": params})
+}
+
+// serverMessage - ответ или уведомление сервера
+type serverMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func (c *client) read() serverMessage {
+	c.t.Helper()
+	length := 0
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("чтение сообщения: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.out, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var msg serverMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// request отправляет запрос и декодирует результат ответа, пропуская уведомления
+func (c *client) request(method string, params any) json.RawMessage {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	c.write(map[string]any{"id": id, "method": method, "params": params})
+	for {
+		msg := c.read()
+		if msg.ID == nil || *msg.ID != id {
+			continue
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s: %s", method, msg.Error.Message)
+		}
+		return msg.Result
+	}
+}
+
+// diagnostics ждет публикацию диагностики документа uri
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p struct {
+			URI         string       `json:"uri"`
+			Diagnostics []Diagnostic `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		if p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(uri, text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "uvm", "version": 1, "text": text},
+	})
+	return c.diagnostics(uri)
+}
+
+func position(uri string, line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+func TestDiagnosticsOnSave(t *testing.T) {
+	c := startServer(t)
+	c.open(libURI, libSource)
+	if diags := c.open(mainURI, mainSource); len(diags) != 0 {
+		t.Fatalf("диагностика корректного файла: %+v", diags)
+	}
+
+	broken := strings.Replace(mainSource, "LOAD R9 771", "LOAD R99 771", 1)
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": mainURI, "version": 2},
+		"contentChanges": []map[string]any{{"text": broken}},
+	})
+	c.notify("textDocument/didSave", map[string]any{"textDocument": map[string]any{"uri": mainURI}})
+
+	diags := c.diagnostics(mainURI)
+	if len(diags) != 1 {
+		t.Fatalf("диагностика: %+v, ожидалась одна ошибка", diags)
+	}
+	// "_start: LOAD R99 771 ; загрузка 🙂" - 33 руны, 34 единицы UTF-16
+	r := diags[0].Range
+	if r.Start.Line != 3 || r.End.Line != 3 || r.End.Character != 34 || r.Start.Character > r.End.Character {
+		t.Errorf("диапазон ошибки %+v, ожидалась строка 3 до символа 34", r)
+	}
+	if diags[0].Severity != severityError || diags[0].Message == "" {
+		t.Errorf("ошибка %+v", diags[0])
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := startServer(t)
+	c.open(mainURI, mainSource)
+
+	var hover struct {
+		Contents struct {
+			Value string `json:"value"`
+		} `json:"contents"`
+		Range Range `json:"range"`
+	}
+	if err := json.Unmarshal(c.request("textDocument/hover", position(mainURI, 3, 9)), &hover); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(hover.Contents.Value, "0x7B, 0x32, 0x30, 0x00, 0x00") {
+		t.Errorf("подсказка LOAD R9 771 без байтов 7B 32 30 00 00:\n%s", hover.Contents.Value)
+	}
+
+	// В строке "; 🙂 R5" регистр начинается с единицы UTF-16 номер 5 (руна 4)
+	if err := json.Unmarshal(c.request("textDocument/hover", position(mainURI, 5, 5)), &hover); err != nil {
+		t.Fatal(err)
+	}
+	want := Range{Start: Position{5, 5}, End: Position{5, 7}}
+	if !strings.Contains(hover.Contents.Value, "R5") || hover.Range != want {
+		t.Errorf("подсказка %q в %+v, ожидался R5 в %+v", hover.Contents.Value, hover.Range, want)
+	}
+	if result := c.request("textDocument/hover", position(mainURI, 5, 4)); string(result) != "null" {
+		t.Errorf("подсказка на пробеле перед R5: %s", result)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := startServer(t)
+	c.open(libURI, libSource)
+	c.open(mainURI, mainSource)
+
+	tests := []struct {
+		name      string
+		line, col int
+		want      Location
+	}{
+		{"метка данных", 4, 13, Location{URI: mainURI, Range: Range{Position{7, 0}, Position{7, 5}}}},
+		{"метка кода", 0, 10, Location{URI: mainURI, Range: Range{Position{3, 0}, Position{3, 6}}}},
+		{"внешний символ", 1, 9, Location{URI: libURI, Range: Range{Position{3, 0}, Position{3, 5}}}},
+	}
+	for _, tt := range tests {
+		var locations []Location
+		if err := json.Unmarshal(c.request("textDocument/definition", position(mainURI, tt.line, tt.col)), &locations); err != nil {
+			t.Fatal(err)
+		}
+		if len(locations) != 1 || locations[0] != tt.want {
+			t.Errorf("%s: %+v, ожидалось %+v", tt.name, locations, tt.want)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := startServer(t)
+	c.open(mainURI, mainSource)
+
+	var items []CompletionItem
+	// После "    SQRT " предлагаются регистры и символы
+	if err := json.Unmarshal(c.request("textDocument/completion", position(mainURI, 4, 9)), &items); err != nil {
+		t.Fatal(err)
+	}
+	labels := make(map[string]bool)
+	for _, item := range items {
+		labels[item.Label] = true
+	}
+	for _, label := range []string{"R63", "value", "_start", "table"} {
+		if !labels[label] {
+			t.Errorf("нет варианта %s", label)
+		}
+	}
+	if labels["LOAD"] {
+		t.Error("после команды предложена команда LOAD")
+	}
+}
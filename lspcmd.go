@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"uvm-assembler/lsp"
+)
+
+// runLSP запускает языковой сервер на стандартных вводе и выводе
+func runLSP(args []string) {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Выводить сообщения протокола в stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: uvm-assembler lsp [-v]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	server := lsp.NewServer(os.Stdin, os.Stdout)
+	if *verbose {
+		server.Log = os.Stderr
+	}
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
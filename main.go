@@ -10,9 +10,19 @@ import (
 
 func main() {
 
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	inputFile := flag.String("input", "", "Путь к исходному файлу с текстом программы")
 	outputFile := flag.String("output", "", "Путь к двоичному файлу-результату")
 	testMode := flag.Bool("test", false, "Режим тестирования (вывод промежуточного представления)")
+	containerMode := flag.Bool("container", false, "Записать программу в контейнер с заголовком, точкой входа и CRC32")
+	objectMode := flag.Bool("object", false, "Записать объектный файл для последующей компоновки (uvm-assembler link)")
+	debugInfo := flag.Bool("g", false, "Записать отладочную информацию (адрес -> файл, строка, столбец) в <output>"+assembler.DebugInfoExt)
 
 	flag.Parse()
 
@@ -47,6 +57,7 @@ func main() {
 	fmt.Printf("Входной файл:  %s\n", *inputFile)
 	fmt.Printf("Выходной файл: %s\n", *outputFile)
 	fmt.Printf("Режим тестирования: %v\n", *testMode)
+	fmt.Printf("Формат контейнера: %v\n", *containerMode)
 	fmt.Println()
 
 	content, err := os.ReadFile(*inputFile)
@@ -58,7 +69,19 @@ func main() {
 	fmt.Printf("✅ Файл прочитан успешно (%d байт)\n", len(content))
 
 	parser := assembler.NewParser(string(content))
-	commands, err := parser.Parse()
+	parser.SetFilename(*inputFile)
+	program, err := parser.ParseProgram()
+	if err != nil {
+		fmt.Printf("Ошибка парсинга: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *objectMode {
+		writeObject(program, *inputFile, *outputFile)
+		return
+	}
+
+	commands, data, err := program.Resolve()
 	if err != nil {
 		fmt.Printf("Ошибка парсинга: %v\n", err)
 		os.Exit(1)
@@ -86,12 +109,31 @@ func main() {
 		}
 	}
 
-	err = os.WriteFile(*outputFile, binaryProgram, 0644)
+	output := binaryProgram
+	if *containerMode {
+		img := assembler.NewImage(binaryProgram)
+		if len(data) > 0 {
+			img.Sections = append(img.Sections, assembler.Section{
+				Kind: assembler.SectionData,
+				Data: assembler.EncodeWords(data),
+			})
+		}
+		output = assembler.MarshalContainer(img)
+	} else if len(data) > 0 {
+		fmt.Println("❌ Секция .data не помещается в сырой формат: используйте -container")
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(*outputFile, output, 0644)
 	if err != nil {
 		fmt.Printf("❌ Ошибка записи файла: %v\n", err)
         os.Exit(1)
 	}
 
+	if *debugInfo {
+		writeDebugInfo(assembler.NewDebugInfo(program.LineTable(0)), *outputFile)
+	}
+
 	fileInfo, _ := os.Stat(*outputFile)
 	fmt.Printf("\n💾 Размер двоичного файла: %d байт\n", fileInfo.Size())
     fmt.Printf("📦 Количество команд: %d\n", len(commands))
@@ -125,8 +167,9 @@ func displayTestResults(commands []assembler.Command) {
 		fmt.Printf("  Поля: %s\n", cmd.ToTestFormat())
 		fmt.Printf("  Детали:\n")
 		
-		for field, value := range cmd.Fields {
-			fmt.Printf("    %s: %d\n", field, value)
+		layout, _ := assembler.Layout(cmd.Type)
+		for _, f := range layout {
+			fmt.Printf("    %s: %d\n", f.Field, cmd.Fields[f.Field])
 		}
 	}
 	
@@ -143,23 +186,23 @@ func verifySpecificationTests(commands []assembler.Command) {
 	// Ожидаемые результаты из спецификации УВМ
 	expectedTests := []struct {
 		name     string
-		expected map[string]uint32
+		expected map[assembler.Field]uint32
 	}{
 		{
 			"Загрузка константы",
-			map[string]uint32{"A": 59, "B": 9, "C": 771},
+			map[assembler.Field]uint32{assembler.FieldA: 59, assembler.FieldB: 9, assembler.FieldC: 771},
 		},
 		{
 			"Чтение значения из памяти", 
-			map[string]uint32{"A": 8, "B": 499, "C": 42, "D": 35},
+			map[assembler.Field]uint32{assembler.FieldA: 8, assembler.FieldB: 499, assembler.FieldC: 42, assembler.FieldD: 35},
 		},
 		{
 			"Запись значения в память",
-			map[string]uint32{"A": 37, "B": 25, "C": 3},
+			map[assembler.Field]uint32{assembler.FieldA: 37, assembler.FieldB: 25, assembler.FieldC: 3},
 		},
 		{
 			"Унарная операция: sqrt()",
-			map[string]uint32{"A": 4, "B": 9, "C": 804},
+			map[assembler.Field]uint32{assembler.FieldA: 4, assembler.FieldB: 9, assembler.FieldC: 804},
 		},
 	}
 	
@@ -176,8 +219,8 @@ func verifySpecificationTests(commands []assembler.Command) {
 			// Проверяем соответствие полей
 			testPassed := true
 			for field, expectedValue := range test.expected {
-				actualValue, exists := cmd.Fields[field]
-				if !exists || actualValue != expectedValue {
+				actualValue := cmd.Fields[field]
+				if actualValue != expectedValue {
 					testPassed = false
 					allTestsPassed = false
 					fmt.Printf("  ❌ Поле %s: ожидалось=%d, получено=%d\n", 
@@ -206,11 +249,11 @@ func verifySpecificationTests(commands []assembler.Command) {
 }
 
 // formatExpected форматирует ожидаемые значения для красивого вывода
-func formatExpected(expected map[string]uint32) string {
+func formatExpected(expected map[assembler.Field]uint32) string {
 	if len(expected) == 3 {
-		return fmt.Sprintf("(A=%d, B=%d, C=%d)", expected["A"], expected["B"], expected["C"])
+		return fmt.Sprintf("(A=%d, B=%d, C=%d)", expected[assembler.FieldA], expected[assembler.FieldB], expected[assembler.FieldC])
 	} else if len(expected) == 4 {
-		return fmt.Sprintf("(A=%d, B=%d, C=%d, D=%d)", expected["A"], expected["B"], expected["C"], expected["D"])
+		return fmt.Sprintf("(A=%d, B=%d, C=%d, D=%d)", expected[assembler.FieldA], expected[assembler.FieldB], expected[assembler.FieldC], expected[assembler.FieldD])
 	}
 	return fmt.Sprintf("%v", expected)
 }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+	"uvm-assembler/assembler"
+	"uvm-assembler/emulator"
+)
+
+// runRun выполняет программу в эмуляторе
+func runRun(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	symbolsFile := fs.String("symbols", "", "Файл символов (JSON), записанный link -symbols")
+	memoryModel := memoryFlags(fs)
+	traceFile := fs.String("trace", "", "Записать трассировку выполнения в файл (- для вывода на экран)")
+	traceFormat := fs.String("trace-format", "text", "Формат трассировки: "+strings.Join(emulator.TraceFormats, ", "))
+	saveSnapshot := fs.String("save-snapshot", "", "Записать снимок состояния после завершения, ошибки или прерывания (Ctrl+C)")
+	loadSnapshot := fs.String("load-snapshot", "", "Продолжить выполнение с состояния из снимка")
+	sqrtOptions := sqrtFlags(fs)
+	devices := deviceFlags(fs)
+	profile := fs.Bool("profile", false, "Вывести профиль: горячие точки, коды операций и обращения к памяти")
+	profileTop := fs.Int("profile-top", 20, "Сколько строк выводить в разделах профиля (0 - все)")
+	pprofFile := fs.String("pprof", "", "Записать профиль в формате pprof (go tool pprof)")
+	heatmapFile := fs.String("heatmap", "", "Записать тепловую карту обращений к памяти в PNG")
+	heatmapRange := fs.String("heatmap-range", "", "Адреса ячеек на карте: от..до (по умолчанию - от первой до последней использованной)")
+	heatmapWidth := fs.Int("heatmap-width", 64, "Ячеек памяти в строке карты")
+	heatmapCell := fs.Int("heatmap-cell", 8, "Сторона ячейки на карте в пикселях")
+	heatmapCounter := fs.String("heatmap-counter", string(emulator.HeatmapAll), "Обращения на карте: "+strings.Join(emulator.HeatmapCounters, ", "))
+	cores := fs.Int("cores", 1, "Число ядер с общей памятью данных; с этим флагом подключается устройство синхронизации")
+	schedule := fs.String("schedule", string(emulator.ScheduleRoundRobin), "Порядок выполнения ядер: "+strings.Join(emulator.Schedules, ", "))
+	seed := fs.Int64("seed", 1, "Начальное значение генератора для -schedule random")
+	quantum := fs.Int("quantum", 1, "Команд подряд на ядре для -schedule round-robin")
+	interpreter := fs.Bool("interpreter", false, "Выполнять простым интерпретатором вместо быстрого ядра с предекодированием")
+	maxSteps := fs.Uint64("max-steps", 0, "Остановить программу после этого числа команд (0 - без ограничения)")
+	timeout := fs.Duration("timeout", 0, "Остановить программу после этого времени выполнения, например 2s (0 - без ограничения)")
+	maxMemory := fs.Int("max-memory", 0, "Наибольший допустимый размер памяти данных в ячейках (0 - без ограничения)")
+	maxOutput := fs.Int64("max-output", 0, "Наибольший вывод программы в байтах (0 - без ограничения)")
+	fs.Usage = func() {
+		fmt.Println("Использование: uvm-assembler run [-trace trace.txt] [-trace-format text|jsonl|chrome] [-save-snapshot state.snap] program.bin")
+		fmt.Println("               uvm-assembler run -load-snapshot state.snap [program.bin]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 || (fs.NArg() == 0 && *loadSnapshot == "") {
+		fs.Usage()
+		os.Exit(1)
+	}
+	multicore := false
+	fs.Visit(func(f *flag.Flag) { multicore = multicore || f.Name == "cores" })
+	if multicore && (*loadSnapshot != "" || *saveSnapshot != "") {
+		fmt.Println("❌ Снимки состояния не поддерживаются для нескольких ядер")
+		os.Exit(1)
+	}
+
+	limits := emulator.Limits{Steps: *maxSteps, Memory: *maxMemory, Output: *maxOutput}
+	model := memoryModel()
+	if limits.Memory > 0 && *loadSnapshot == "" && model.Size > limits.Memory {
+		exitLimit(&emulator.LimitError{Kind: emulator.LimitMemory, Limit: uint64(limits.Memory)})
+	}
+
+	vm, program := newMachine(fs.Args(), *loadSnapshot, *symbolsFile, model)
+	vm.SqrtMode, vm.SqrtSigned = sqrtOptions()
+	console := devices(vm, os.Stdin, emulator.LimitWriter(os.Stdout, limits.Output))
+	if *profile || *pprofFile != "" || *heatmapFile != "" {
+		vm.Profile = emulator.NewProfile(vm.Len())
+	}
+	var err error
+
+	if *traceFile != "" {
+		out := os.Stdout
+		if *traceFile != "-" {
+			if out, err = os.Create(*traceFile); err != nil {
+				fmt.Printf("❌ Ошибка создания файла трассировки: %v\n", err)
+				os.Exit(1)
+			}
+			defer out.Close()
+		}
+
+		if vm.Tracer, err = emulator.NewTracer(*traceFormat, out); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Ctrl+C останавливает выполнение между командами, чтобы можно было
+	// сохранить снимок и продолжить позже
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	run := vm.RunFast
+	if *interpreter {
+		run = vm.RunContext
+	}
+	var machine *emulator.Multicore
+	if multicore {
+		base := uint32(fs.Lookup("device-base").Value.(flag.Getter).Get().(uint))
+		machine, err = emulator.NewMulticore(vm, emulator.MulticoreOptions{
+			Cores:    *cores,
+			Schedule: emulator.Schedule(*schedule),
+			Seed:     *seed,
+			Quantum:  *quantum,
+			SyncBase: base + emulator.SyncDeviceOffset*vm.Model.WordCells(),
+		})
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		run = machine.RunContext
+	}
+
+	start := time.Now()
+	runErr := run(ctx, limits)
+	elapsed := time.Since(start)
+	stop()
+	if errors.Is(runErr, context.Canceled) {
+		runErr = nil
+	}
+	if console != nil && !console.EndsLine() {
+		fmt.Println()
+	}
+
+	if vm.Tracer != nil {
+		if err := vm.Tracer.Close(); err != nil {
+			fmt.Printf("❌ Ошибка записи трассировки: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *saveSnapshot != "" {
+		if err := vm.SaveSnapshot(*saveSnapshot); err != nil {
+			fmt.Printf("❌ Ошибка записи снимка: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📦 Снимок состояния на шаге %d записан в %s\n", vm.Steps, *saveSnapshot)
+	}
+	if *profile {
+		vm.Profile.WriteReport(os.Stdout, vm, program.symbols, *profileTop)
+	}
+	if *pprofFile != "" {
+		if err := writePprof(*pprofFile, vm, program.symbols, fs.Args(), elapsed); err != nil {
+			fmt.Printf("❌ Ошибка записи профиля: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📊 Профиль pprof записан в %s (go tool pprof %s)\n", *pprofFile, *pprofFile)
+	}
+	if *heatmapFile != "" {
+		opts := emulator.HeatmapOptions{RowWidth: *heatmapWidth, CellSize: *heatmapCell, Counter: emulator.HeatmapCounter(*heatmapCounter)}
+		if err := writeHeatmap(*heatmapFile, *heatmapRange, vm.Profile, program.symbols, opts); err != nil {
+			fmt.Printf("❌ Ошибка записи тепловой карты: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if machine != nil {
+		printRaces(machine, program.symbols)
+	}
+	if runErr != nil {
+		var fault *emulator.Fault
+		var limitErr *emulator.LimitError
+		if errors.As(runErr, &limitErr) {
+			exitLimit(limitErr)
+		}
+		if errors.As(runErr, &fault) {
+			printFault(fault)
+			if machine != nil {
+				fmt.Printf("   Ядро:     %d\n", machine.Last)
+			}
+			os.Exit(fault.Kind.ExitCode())
+		}
+		fmt.Printf("❌ Ошибка выполнения: %v\n", runErr)
+		os.Exit(1)
+	}
+	if machine != nil {
+		finishMulticore(machine)
+		return
+	}
+	if !vm.Halted {
+		fmt.Printf("⏸️  Выполнение прервано на шаге %d (PC = %d)\n", vm.Steps, vm.PC)
+		os.Exit(130)
+	}
+
+	if vm.Exited {
+		fmt.Printf("✅ Программа завершилась с кодом %d: %d команд\n", vm.ExitCode, vm.Steps)
+		os.Exit(int(vm.ExitCode & 0xFF))
+	}
+	fmt.Printf("✅ Программа выполнена: %d команд\n", vm.Steps)
+}
+
+// finishMulticore сообщает итог выполнения на нескольких ядрах и завершает процесс
+// с кодом программы
+func finishMulticore(m *emulator.Multicore) {
+	perCore := make([]string, len(m.Cores))
+	for i, core := range m.Cores {
+		perCore[i] = strconv.FormatUint(core.Steps, 10)
+	}
+	counts := strings.Join(perCore, " + ")
+
+	if !m.Halted {
+		fmt.Printf("⏸️  Выполнение прервано на шаге %d (%s)\n", m.Steps, counts)
+		os.Exit(130)
+	}
+	if exited, code := m.Exited(); exited {
+		fmt.Printf("✅ Программа завершилась с кодом %d: %d команд (%s)\n", code, m.Steps, counts)
+		os.Exit(int(code & 0xFF))
+	}
+	fmt.Printf("✅ Программа выполнена на %d ядрах: %d команд (%s)\n", len(m.Cores), m.Steps, counts)
+}
+
+// printRaces выводит найденные гонки данных с местом обеих команд в исходном тексте
+func printRaces(m *emulator.Multicore, symbols *assembler.SymbolTable) {
+	if len(m.Races) == 0 {
+		fmt.Println("✅ Гонок данных не обнаружено")
+		return
+	}
+	vm := m.Cores[0]
+	describe := func(a emulator.RaceAccess) string {
+		kind := "чтение"
+		if a.Kind == emulator.AccessWrite {
+			kind = "запись"
+		}
+		text := fmt.Sprintf("ядро %d, %s на шаге %d, PC %d", a.Core, kind, a.Step, a.PC)
+		if cmd, err := vm.Fetch(a.PC); err == nil {
+			text += ": " + assembler.Disassemble(cmd)
+		}
+		if entry, ok := vm.Debug.Lookup(a.PC); ok {
+			text += " (" + entry.SourceLocation.String() + ")"
+		}
+		return text
+	}
+
+	fmt.Printf("⚠️  Гонки данных: %d\n", len(m.Races))
+	for _, r := range m.Races {
+		fmt.Printf("   ячейка %d", r.Addr)
+		if name := symbols.Describe(assembler.SectionData, r.Addr); name != "" {
+			fmt.Printf(" (%s)", name)
+		}
+		fmt.Println()
+		fmt.Printf("     %s\n", describe(r.First))
+		fmt.Printf("     %s\n", describe(r.Second))
+	}
+}
+
+// exitLimit сообщает о превышении лимита и завершает процесс с кодом вида лимита
+func exitLimit(err *emulator.LimitError) {
+	fmt.Printf("⛔ Выполнение остановлено: %v\n", err)
+	os.Exit(err.Kind.ExitCode())
+}
+
+// printFault выводит отчет об ошибке выполнения
+func printFault(f *emulator.Fault) {
+	fmt.Printf("❌ Ошибка выполнения: %s\n", f.Kind)
+	fmt.Printf("   PC:       %d (шаг %d)\n", f.PC, f.Step)
+	if f.Instruction != "" {
+		fmt.Printf("   Команда:  %s\n", f.Instruction)
+	}
+	if f.Source != nil {
+		fmt.Printf("   Источник: %s\n", f.Source)
+	}
+	fmt.Printf("   Причина:  %v\n", f.Err)
+}
+
+// writePprof записывает профиль выполнения в файл формата pprof
+func writePprof(path string, vm *emulator.VM, symbols *assembler.SymbolTable, args []string, elapsed time.Duration) error {
+	name := "snapshot"
+	if len(args) > 0 {
+		name = args[0]
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := vm.Profile.WritePprof(out, vm, symbols, name, elapsed); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeHeatmap записывает тепловую карту обращений к памяти. Диапазон задается
+// адресами или символами данных в виде от..до; пустой диапазон - все использованные ячейки.
+func writeHeatmap(path, addrRange string, profile *emulator.Profile, symbols *assembler.SymbolTable, opts emulator.HeatmapOptions) error {
+	if addrRange == "" {
+		from, to, ok := profile.MemoryRange()
+		if !ok {
+			fmt.Println("⚠️  Программа не обращалась к памяти: тепловая карта не записана")
+			return nil
+		}
+		opts.From, opts.To = from, to
+	} else {
+		from, to, ok := strings.Cut(addrRange, "..")
+		if !ok {
+			return fmt.Errorf("диапазон адресов записывается как от..до: %s", addrRange)
+		}
+		var err error
+		if opts.From, err = resolveDataAddress(from, symbols); err != nil {
+			return err
+		}
+		if opts.To, err = resolveDataAddress(to, symbols); err != nil {
+			return err
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	peak, err := profile.WriteHeatmap(out, opts)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("🌡️  Тепловая карта ячеек %d..%d записана в %s (наибольшее число обращений: %d)\n", opts.From, opts.To, path, peak)
+	return nil
+}
+
+// resolveDataAddress разбирает адрес памяти: число или символ данных
+func resolveDataAddress(s string, symbols *assembler.SymbolTable) (uint32, error) {
+	if v, err := strconv.ParseUint(s, 0, 32); err == nil {
+		return uint32(v), nil
+	}
+	if symbols != nil {
+		if sym, ok := symbols.Find(s); ok && sym.Section == assembler.SectionData.String() {
+			return sym.Address, nil
+		}
+	}
+	return 0, fmt.Errorf("неверный адрес: %s", s)
+}
@@ -0,0 +1,257 @@
+// Package uvm - встраиваемый интерфейс ассемблера и эмулятора УВМ: сборка
+// и выполнение программы в одном вызове, без вывода в stdout и без завершения процесса.
+//
+//	prog, diags, err := uvm.Assemble(ctx, strings.NewReader(src), uvm.Options{Filename: "main.asm"})
+//	if err != nil {
+//		// diags - ошибки исходного текста с номерами строк
+//	}
+//	res, err := uvm.Run(ctx, prog, uvm.VMOptions{Limits: emulator.Limits{Steps: 1e6}})
+package uvm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+	"uvm-assembler/assembler"
+	"uvm-assembler/emulator"
+)
+
+// ErrAssembly возвращается Assemble, если в исходном тексте есть ошибки;
+// сами ошибки - в списке диагностик
+var ErrAssembly = errors.New("программа содержит ошибки")
+
+// Options - параметры сборки
+type Options struct {
+	// Filename - имя исходного файла в диагностике и отладочной информации
+	Filename string
+}
+
+// Diagnostic - ошибка исходного текста. Line и Column начинаются с 1;
+// 0 - место неизвестно.
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	loc := assembler.SourceLocation{File: d.File, Line: d.Line, Column: d.Column}
+	if d.Line == 0 {
+		if d.File == "" {
+			return d.Message
+		}
+		return d.File + ": " + d.Message
+	}
+	return loc.String() + ": " + d.Message
+}
+
+// Program - собранная программа с таблицей строк для сообщений об ошибках
+type Program struct {
+	Image *assembler.Image
+	Debug *assembler.DebugInfo // nil для программ из Load
+}
+
+// Binary возвращает программу в формате контейнера (как ассемблер с -container)
+func (p *Program) Binary() []byte {
+	return assembler.MarshalContainer(p.Image)
+}
+
+// Load читает двоичную программу: контейнер или старый формат без заголовка
+func Load(data []byte) (*Program, error) {
+	img, err := assembler.LoadImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{Image: img}, nil
+}
+
+// Assemble собирает программу из исходного текста. Ошибки исходного текста
+// возвращаются все сразу в списке диагностик вместе с ErrAssembly; другие
+// ошибки (чтения r, отмены ctx) - без диагностик. Программа с внешними
+// символами (.extern) не собирается: ее нужно компоновать.
+func Assemble(ctx context.Context, r io.Reader, opts Options) (*Program, []Diagnostic, error) {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	parser := assembler.NewParser(string(source))
+	parser.SetFilename(opts.Filename)
+	program, errs := parser.Check()
+	if len(errs) == 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		img, err := program.Image()
+		if err == nil {
+			return &Program{Image: img, Debug: assembler.NewDebugInfo(program.LineTable(0))}, nil, nil
+		}
+		var srcErr *assembler.SourceError
+		if !errors.As(err, &srcErr) {
+			srcErr = &assembler.SourceError{Err: err}
+		}
+		errs = append(errs, srcErr)
+	}
+
+	diags := make([]Diagnostic, len(errs))
+	for i, e := range errs {
+		diags[i] = Diagnostic{File: opts.Filename, Line: e.Line, Column: e.Column, Message: e.Err.Error()}
+	}
+	return nil, diags, ErrAssembly
+}
+
+// VMOptions - параметры выполнения. Нулевое значение - машина по умолчанию:
+// 65536 слов памяти, SQRT с округлением вниз, стандартные устройства с пустым
+// вводом и выводом в Result.Output, одно ядро, без ограничений.
+type VMOptions struct {
+	Memory     emulator.MemoryModel // незаданные поля - по умолчанию
+	SqrtMode   emulator.SqrtMode
+	SqrtSigned bool
+
+	NoDevices  bool      // не подключать стандартные устройства
+	DeviceBase uint32    // 0 - emulator.DefaultDeviceBase
+	Input      io.Reader // порт ввода; nil - ввод сразу заканчивается
+	Output     io.Writer // порт вывода; nil - вывод собирается в Result.Output
+
+	Limits emulator.Limits
+
+	// Cores > 1 - выполнение на нескольких ядрах с поиском гонок (см. emulator.Multicore)
+	Cores    int
+	Schedule emulator.Schedule
+	Seed     int64
+	Quantum  int
+
+	Interpreter bool // пошаговый интерпретатор вместо быстрого ядра
+	Tracer      emulator.Tracer
+	Profile     bool // собрать emulator.Profile в Result.Profile
+}
+
+// Status - итог выполнения программы
+type Status string
+
+const (
+	StatusHalted Status = "halted" // PC дошел до конца программы
+	StatusExited Status = "exited" // программа записала код в порт останова
+	StatusFault  Status = "fault"  // ошибка выполнения (Result.Fault)
+	StatusLimit  Status = "limit"  // превышен лимит (Result.Limit)
+)
+
+// Result - итог выполнения и состояние машины после него
+type Result struct {
+	Status   Status
+	ExitCode uint32 // для StatusExited
+	Fault    *emulator.Fault
+	Limit    *emulator.LimitError
+	Races    []emulator.Race // при Cores > 1
+
+	Steps    uint64 // команд на всех ядрах
+	Duration time.Duration
+	Output   []byte // если VMOptions.Output не задан
+
+	// VM - машина (при Cores > 1 - ядро 0): регистры, PC и память после выполнения
+	VM      *emulator.VM
+	Profile *emulator.Profile
+}
+
+// Run выполняет программу. Ошибка выполнения и превышение лимита, в том числе
+// истечение срока ctx, - обычный итог: они возвращаются в Result без ошибки.
+// Ошибка возвращается при неверных параметрах, отмене ctx и ошибках ввода-вывода;
+// в последних двух случаях Result содержит состояние на момент остановки.
+func Run(ctx context.Context, p *Program, opts VMOptions) (*Result, error) {
+	model := opts.Memory
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Limits.Memory > 0 && model.Size > opts.Limits.Memory {
+		limit := &emulator.LimitError{Kind: emulator.LimitMemory, Limit: uint64(opts.Limits.Memory)}
+		return &Result{Status: StatusLimit, Limit: limit}, nil
+	}
+	if opts.SqrtMode != "" {
+		if _, err := emulator.ParseSqrtMode(string(opts.SqrtMode)); err != nil {
+			return nil, err
+		}
+	}
+
+	vm, err := emulator.NewWithModel(p.Image, model)
+	if err != nil {
+		return nil, err
+	}
+	vm.Debug, vm.Tracer = p.Debug, opts.Tracer
+	vm.SqrtMode, vm.SqrtSigned = opts.SqrtMode, opts.SqrtSigned
+	if opts.Profile {
+		vm.Profile = emulator.NewProfile(vm.Len())
+	}
+
+	result := &Result{VM: vm, Profile: vm.Profile}
+	base := opts.DeviceBase
+	if base == 0 {
+		base = emulator.DefaultDeviceBase
+	}
+	var output bytes.Buffer
+	if !opts.NoDevices {
+		in, out := opts.Input, opts.Output
+		if in == nil {
+			in = bytes.NewReader(nil)
+		}
+		if out == nil {
+			out = &output
+		}
+		if _, err := vm.AttachStandardDevices(base, in, emulator.LimitWriter(out, opts.Limits.Output)); err != nil {
+			return nil, err
+		}
+	}
+
+	run := vm.RunFast
+	if opts.Interpreter {
+		run = vm.RunContext
+	}
+	var machine *emulator.Multicore
+	if opts.Cores > 1 {
+		machine, err = emulator.NewMulticore(vm, emulator.MulticoreOptions{
+			Cores:    opts.Cores,
+			Schedule: opts.Schedule,
+			Seed:     opts.Seed,
+			Quantum:  opts.Quantum,
+			SyncBase: base + emulator.SyncDeviceOffset*vm.Model.WordCells(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		run = machine.RunContext
+	}
+
+	start := time.Now()
+	runErr := run(ctx, opts.Limits)
+	result.Duration = time.Since(start)
+	result.Output = output.Bytes()
+	result.Steps = vm.Steps
+	if machine != nil {
+		result.Steps, result.Races = machine.Steps, machine.Races
+	}
+
+	exited, code := vm.Exited, vm.ExitCode
+	if machine != nil {
+		exited, code = machine.Exited()
+	}
+
+	var fault *emulator.Fault
+	switch {
+	case errors.As(runErr, &result.Limit):
+		result.Status = StatusLimit
+	case errors.As(runErr, &fault):
+		result.Status, result.Fault = StatusFault, fault
+	case runErr != nil:
+		return result, runErr
+	case exited:
+		result.Status, result.ExitCode = StatusExited, code
+	default:
+		result.Status = StatusHalted
+	}
+	return result, nil
+}
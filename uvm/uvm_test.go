@@ -0,0 +1,156 @@
+package uvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"uvm-assembler/emulator"
+)
+
+func assemble(t *testing.T, source string) *Program {
+	t.Helper()
+	prog, diags, err := Assemble(context.Background(), strings.NewReader(source), Options{Filename: "main.asm"})
+	if err != nil {
+		t.Fatalf("%v: %v", err, diags)
+	}
+	return prog
+}
+
+// Порты стандартных устройств по адресу emulator.DefaultDeviceBase
+var (
+	outPort  = emulator.DefaultDeviceBase
+	haltPort = emulator.DefaultDeviceBase + 2
+)
+
+func TestAssembleDiagnostics(t *testing.T) {
+	source := "LOAD R1 1\nLOAD R99 1\nSQRT R1 5\nJUMP 3\n"
+	prog, diags, err := Assemble(context.Background(), strings.NewReader(source), Options{Filename: "bad.asm"})
+	if !errors.Is(err, ErrAssembly) || prog != nil {
+		t.Fatalf("Assemble: %v, %v, ожидалась ErrAssembly", prog, err)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("диагностика %v, ожидалось две ошибки", diags)
+	}
+	for i, line := range []int{2, 4} {
+		if d := diags[i]; d.File != "bad.asm" || d.Line != line || d.Message == "" {
+			t.Errorf("ошибка %d: %+v, ожидалась строка %d файла bad.asm", i, d, line)
+		}
+	}
+	if !strings.HasPrefix(diags[0].String(), "bad.asm:2:") {
+		t.Errorf("String() = %q", diags[0].String())
+	}
+}
+
+func TestRunStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		opts   VMOptions
+		status Status
+		check  func(t *testing.T, res *Result)
+	}{
+		{
+			name:   "halted",
+			source: "LOAD R1 16\nSQRT R1 7\n",
+			status: StatusHalted,
+			check: func(t *testing.T, res *Result) {
+				if res.VM.Memory[7] != 4 || res.Steps != 2 {
+					t.Errorf("mem[7] = %d, шагов %d; ожидалось 4 и 2", res.VM.Memory[7], res.Steps)
+				}
+				if res.VM.Model.Size != 65536 {
+					t.Errorf("память %d слов, по умолчанию ожидалось 65536", res.VM.Model.Size)
+				}
+			},
+		},
+		{
+			name:   "exited",
+			source: fmt.Sprintf("LOAD R1 %d\nLOAD R2 72\nWRITE R2 R1\nLOAD R1 %d\nLOAD R2 3\nWRITE R2 R1\nLOAD R9 1\n", outPort, haltPort),
+			status: StatusExited,
+			check: func(t *testing.T, res *Result) {
+				if res.ExitCode != 3 || string(res.Output) != "H" {
+					t.Errorf("код %d, вывод %q; ожидалось 3 и \"H\"", res.ExitCode, res.Output)
+				}
+				if res.VM.Regs[9] != 0 {
+					t.Error("команда после записи в порт останова выполнена")
+				}
+			},
+		},
+		{
+			name:   "fault",
+			source: "LOAD R1 1\nLOAD R2 1000000\nWRITE R1 R2\n",
+			status: StatusFault,
+			check: func(t *testing.T, res *Result) {
+				if res.Fault == nil || res.Fault.PC != 2 || res.Fault.Kind != emulator.FaultMemory {
+					t.Errorf("ошибка %+v, ожидалась FaultMemory на команде 2", res.Fault)
+				}
+			},
+		},
+		{
+			name:   "limit steps",
+			source: "LOAD R1 1\nLOAD R2 2\nLOAD R3 3\n",
+			opts:   VMOptions{Limits: emulator.Limits{Steps: 2}},
+			status: StatusLimit,
+			check: func(t *testing.T, res *Result) {
+				if res.Limit == nil || res.Limit.Kind != emulator.LimitSteps || res.Steps != 2 {
+					t.Errorf("лимит %+v после %d шагов, ожидался LimitSteps после 2", res.Limit, res.Steps)
+				}
+			},
+		},
+		{
+			name:   "limit memory",
+			source: "LOAD R1 1\n",
+			opts:   VMOptions{Limits: emulator.Limits{Memory: 1024}},
+			status: StatusLimit,
+			check: func(t *testing.T, res *Result) {
+				if res.Limit == nil || res.Limit.Kind != emulator.LimitMemory || res.VM != nil {
+					t.Errorf("лимит %+v, машина %v; ожидался LimitMemory без запуска", res.Limit, res.VM)
+				}
+			},
+		},
+		{
+			name:   "cores",
+			source: "LOAD R1 25\nSQRT R1 0\n",
+			opts:   VMOptions{Cores: 2},
+			status: StatusHalted,
+			check: func(t *testing.T, res *Result) {
+				if res.Steps != 4 || res.VM.Memory[0] != 5 {
+					t.Errorf("шагов %d, mem[0] = %d; ожидалось 4 и 5", res.Steps, res.VM.Memory[0])
+				}
+				if len(res.Races) != 1 || res.Races[0].Addr != 0 {
+					t.Errorf("гонки %+v, ожидалась одна по адресу 0", res.Races)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, interpreter := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/interpreter=%v", tt.name, interpreter), func(t *testing.T) {
+				opts := tt.opts
+				opts.Interpreter = interpreter
+				res, err := Run(context.Background(), assemble(t, tt.source), opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Status != tt.status {
+					t.Fatalf("статус %s, ожидался %s (ошибка %v, лимит %v)", res.Status, tt.status, res.Fault, res.Limit)
+				}
+				tt.check(t, res)
+			})
+		}
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := Run(ctx, assemble(t, "LOAD R1 1\n"), VMOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ошибка %v, ожидалась context.Canceled", err)
+	}
+	if res == nil || res.VM == nil {
+		t.Error("при отмене нет состояния машины")
+	}
+}